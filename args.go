@@ -0,0 +1,160 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArgInt returns the i-th argument in args, converted to an int.  The error
+// message names the argument position, starting from 1.
+func (c *Command) ArgInt(args []string, i int) (int, error) {
+	s, err := arg(args, i)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("argument %d: invalid integer %q", i+1, s)
+	}
+
+	return n, nil
+}
+
+// ArgDuration returns the i-th argument in args, converted to a
+// time.Duration.  The error message names the argument position, starting
+// from 1.
+func (c *Command) ArgDuration(args []string, i int) (time.Duration, error) {
+	s, err := arg(args, i)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("argument %d: invalid duration %q", i+1, s)
+	}
+
+	return d, nil
+}
+
+// arg returns the i-th argument in args, or an error if it is missing.
+func arg(args []string, i int) (string, error) {
+	if i < 0 || i >= len(args) {
+		return "", fmt.Errorf("argument %d: missing", i+1)
+	}
+
+	return args[i], nil
+}
+
+// checkArgs checks that the number of arguments is in the range set by
+// c.MinArgs and c.MaxArgs, and then validates the arguments with c.Args.
+func (c *Command) checkArgs(args []string) error {
+	if err := checkRange(args, c.MinArgs, c.MaxArgs); err != nil {
+		return err
+	}
+	if c.Args != nil {
+		return c.Args(c, args)
+	}
+
+	return nil
+}
+
+// checkRange checks that the number of arguments is in the range from min to
+// max.  A negative or 0 max means no maximum.  When both are 0, the number of
+// arguments is not checked.
+func checkRange(args []string, min, max int) error {
+	if min == 0 && max == 0 {
+		return nil
+	}
+	if max == 0 {
+		max = -1
+	}
+	n := len(args)
+	if n >= min && (max < 0 || n <= max) {
+		return nil
+	}
+
+	var want string
+	switch {
+	case min == max:
+		want = nargs(min)
+	case max < 0:
+		want = "at least " + nargs(min)
+	case min == 0:
+		want = "at most " + nargs(max)
+	default:
+		want = fmt.Sprintf("%d to %d arguments", min, max)
+	}
+
+	return fmt.Errorf("expected %s, got %d", want, n)
+}
+
+// nargs returns "1 argument" or "n arguments".
+func nargs(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// ExactArgs returns an Args function that accepts exactly n arguments.
+func ExactArgs(n int) func(cmd *Command, args []string) error {
+	return func(cmd *Command, args []string) error {
+		if len(args) != n {
+			return fmt.Errorf("expected %s, got %d", nargs(n), len(args))
+		}
+
+		return nil
+	}
+}
+
+// MinimumNArgs returns an Args function that accepts at least n arguments.
+func MinimumNArgs(n int) func(cmd *Command, args []string) error {
+	return func(cmd *Command, args []string) error {
+		if len(args) < n {
+			return fmt.Errorf("expected at least %s, got %d", nargs(n),
+				len(args))
+		}
+
+		return nil
+	}
+}
+
+// NoArgs is an Args function that accepts no arguments.
+func NoArgs(cmd *Command, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected argument %q", args[0])
+	}
+
+	return nil
+}
+
+// OnlyValidArgs is an Args function that accepts only the arguments listed in
+// cmd.ValidArgs.
+func OnlyValidArgs(cmd *Command, args []string) error {
+	for _, arg := range args {
+		if !contains(cmd.ValidArgs, arg) {
+			return fmt.Errorf("invalid argument %q, expected one of %s", arg,
+				strings.Join(cmd.ValidArgs, ", "))
+		}
+	}
+
+	return nil
+}
+
+// contains reports whether s is in list.
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+
+	return false
+}
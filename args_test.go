@@ -0,0 +1,184 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestCommandArgInt tests the Command.ArgInt method.
+func TestCommandArgInt(t *testing.T) {
+	args := list{"10", "x"}
+	var tests = []struct {
+		i    int
+		want int
+		err  string // expected error message
+	}{
+		{0, 10, ""},
+		{1, 0, `argument 2: invalid integer "x"`},
+		{2, 0, "argument 3: missing"},
+	}
+
+	var cmd Command
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.i), func(t *testing.T) {
+			got, err := cmd.ArgInt(args, test.i)
+			if msg := errorString(err); msg != test.err {
+				t.Errorf("got error %q, want %q", msg, test.err)
+			}
+			if got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCommandArgDuration tests the Command.ArgDuration method.
+func TestCommandArgDuration(t *testing.T) {
+	args := list{"2m3s", "10"}
+	var tests = []struct {
+		i    int
+		want time.Duration
+		err  string // expected error message
+	}{
+		{0, 2*time.Minute + 3*time.Second, ""},
+		{1, 0, `argument 2: invalid duration "10"`},
+		{-1, 0, "argument 0: missing"},
+	}
+
+	var cmd Command
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.i), func(t *testing.T) {
+			got, err := cmd.ArgDuration(args, test.i)
+			if msg := errorString(err); msg != test.err {
+				t.Errorf("got error %q, want %q", msg, test.err)
+			}
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCommandCheckArgs tests that the number of arguments is checked against
+// MinArgs and MaxArgs.
+func TestCommandCheckArgs(t *testing.T) {
+	var tests = []struct {
+		min, max int
+		args     list
+		err      string // expected error message
+	}{
+		{0, 0, list{"a", "b"}, ""},
+		{1, 1, list{"a"}, ""},
+		{1, 1, list{}, "expected 1 argument, got 0"},
+		{2, 2, list{"a"}, "expected 2 arguments, got 1"},
+		{1, -1, list{"a", "b", "c"}, ""},
+		{2, -1, list{"a"}, "expected at least 2 arguments, got 1"},
+		{1, 0, list{"a", "b", "c"}, ""},
+		{2, 0, list{"a"}, "expected at least 2 arguments, got 1"},
+		{0, 1, list{}, ""},
+		{0, 1, list{"a", "b"}, "expected at most 1 argument, got 2"},
+		{1, 2, list{"a", "b"}, ""},
+		{1, 2, list{}, "expected 1 to 2 arguments, got 0"},
+		{1, 2, list{"a", "b", "c"}, "expected 1 to 2 arguments, got 3"},
+	}
+
+	for _, test := range tests {
+		name := fmt.Sprintf("%d:%d:%d", test.min, test.max, len(test.args))
+		t.Run(name, func(t *testing.T) {
+			cmd := Command{MinArgs: test.min, MaxArgs: test.max}
+			err := cmd.checkArgs(test.args)
+			if msg := errorString(err); msg != test.err {
+				t.Errorf("got error %q, want %q", msg, test.err)
+			}
+		})
+	}
+}
+
+// TestRunArgs tests that Run reports a usage error when the number of
+// arguments is out of range, without running the command.
+func TestRunArgs(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	cmd := main.Commands[0]
+	cmd.MinArgs = 1
+	cmd.MaxArgs = 2
+	calls := 0
+	cmd.Run = func(cmd *Command, args []string) int {
+		calls++
+
+		return ExitSuccess
+	}
+
+	status := 0
+	output := capture(t, func() {
+		status = run(main, list{"app", "cmd", "a", "b", "c"})
+	})
+	if status != ExitUsageError {
+		t.Errorf("got status %d, want %d", status, ExitUsageError)
+	}
+	want := "app cmd: expected 1 to 2 arguments, got 3\nusage: app cmd \n"
+	if output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+
+	if status := run(main, list{"app", "cmd", "a"}); status != ExitSuccess {
+		t.Errorf("got status %d, want %d", status, ExitSuccess)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want %d", calls, 1)
+	}
+
+	// Only MinArgs set means no maximum.
+	cmd.MaxArgs = 0
+	status = run(main, list{"app", "cmd", "a", "b", "c"})
+	if status != ExitSuccess {
+		t.Errorf("got status %d, want %d", status, ExitSuccess)
+	}
+}
+
+// TestArgsValidators tests the Args functions provided by the package.
+func TestArgsValidators(t *testing.T) {
+	cmd := &Command{ValidArgs: list{"bash", "zsh"}}
+	var tests = []struct {
+		name string
+		fn   func(*Command, []string) error
+		args list
+		err  string // expected error message
+	}{
+		{"ExactArgs", ExactArgs(2), list{"a", "b"}, ""},
+		{"ExactArgs", ExactArgs(2), list{"a"},
+			"expected 2 arguments, got 1"},
+		{"ExactArgs", ExactArgs(1), list{"a", "b"},
+			"expected 1 argument, got 2"},
+		{"MinimumNArgs", MinimumNArgs(1), list{"a", "b"}, ""},
+		{"MinimumNArgs", MinimumNArgs(2), list{"a"},
+			"expected at least 2 arguments, got 1"},
+		{"NoArgs", NoArgs, list{}, ""},
+		{"NoArgs", NoArgs, list{"a"}, `unexpected argument "a"`},
+		{"OnlyValidArgs", OnlyValidArgs, list{"zsh", "bash"}, ""},
+		{"OnlyValidArgs", OnlyValidArgs, list{"zsh", "tcsh"},
+			`invalid argument "tcsh", expected one of bash, zsh`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name+":"+join(test.args), func(t *testing.T) {
+			err := test.fn(cmd, test.args)
+			if msg := errorString(err); msg != test.err {
+				t.Errorf("got error %q, want %q", msg, test.err)
+			}
+		})
+	}
+}
+
+// errorString returns the message of err, or an empty string if err is nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -12,11 +12,18 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
+
+	"github.com/perillo/cmd/cmdstate"
 )
 
 // Standard Posix exit status constants.
@@ -26,6 +33,35 @@ const (
 	ExitUsageError
 )
 
+// Exit status used by Run in case of parsing error, by error category.  They
+// can be changed to match the conventions of the platform, like 127 for an
+// unknown command.
+var (
+	// FlagErrorCode is the exit status for an invalid flag or flag value,
+	// and for the other parsing errors not listed below.
+	FlagErrorCode = ExitUsageError
+
+	// UnknownCommandCode is the exit status for ErrUnknownCommand.
+	UnknownCommandCode = ExitUsageError
+
+	// NoCommandCode is the exit status for ErrNoCommand.
+	NoCommandCode = ExitUsageError
+)
+
+// parseErrorCode returns the exit status for the parsing error err.
+func parseErrorCode(err error) int {
+	switch err {
+	case flag.ErrHelp:
+		return ExitUsageError
+	case ErrUnknownCommand:
+		return UnknownCommandCode
+	case ErrNoCommand:
+		return NoCommandCode
+	}
+
+	return FlagErrorCode
+}
+
 // ErrHelp is the error returned by Parse if the -help or -h flag is invoked
 // but no such flag is defined.
 var ErrHelp = flag.ErrHelp
@@ -37,15 +73,108 @@ var ErrNoCommand = errors.New("no command")
 // invoked.
 var ErrUnknownCommand = errors.New("unknown command")
 
+// errRecursiveInvocation is the error returned by parseCommands when a running
+// command is invoked again.
+var errRecursiveInvocation = errors.New("recursive invocation")
+
+// ErrVersion is the error returned by Parse if the -version or -V flag is
+// invoked on a main command with Version set.
+var ErrVersion = errors.New("version requested")
+
+// usageFooter is the footer printed at the end of the default usage output.
+var usageFooter string
+
+// SetUsageFooter sets the message printed at the end of the default usage
+// output of all the commands that do not specify a Footer.  An empty text
+// means that no footer will be printed.
+func SetUsageFooter(text string) {
+	usageFooter = text
+}
+
+// ExitCoder is the interface implemented by errors that carry an exit status.
+// When an error returned by Command.RunE implements ExitCoder, its exit
+// status is used.  Note that *exec.ExitError implements ExitCoder.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// Exit status constants used by ExitCodeFromError for context errors.
+const (
+	ExitTimeout   = 124 // like timeout(1)
+	ExitInterrupt = 130 // like a process terminated by SIGINT
+)
+
+// ExitCodeFromError returns the exit status carried by err.  It returns
+// ExitSuccess if err is nil, the exit status of the first error in err's
+// chain that implements ExitCoder, ExitTimeout for context.DeadlineExceeded,
+// ExitInterrupt for context.Canceled and ExitFailure otherwise.
+//
+// It is useful for commands that run a sub process with os/exec and want to
+// exit with the same status.
+func ExitCodeFromError(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+
+	var e ExitCoder
+	if errors.As(err, &e) {
+		// A process terminated by a signal reports -1.
+		if code := e.ExitCode(); code > 0 {
+			return code
+		}
+
+		return ExitFailure
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupt
+	}
+
+	return ExitFailure
+}
+
 // A Command is an implementation of a single command.
 type Command struct {
 	// Run runs the command and returns the exit status.
 	// The args are the arguments after the command name.
 	Run func(cmd *Command, args []string) int
 
-	// Usage prints the command usage to os.Stderr.  If not specified a default
-	// template will be used, printing UsageLine, followed by a call to
-	// Flag.PrintDefaults and a list of available sub commands.
+	// RunE is like Run, but returns an error instead of the exit status.  A
+	// non nil error is printed to os.Stderr, prefixed by the command name,
+	// and the exit status is the one returned by ExitCodeFromError.
+	RunE func(cmd *Command, args []string) error
+
+	// RunContext is like Run, but it is also passed the context specified to
+	// RunWithContext.  Only one of Run, RunE and RunContext may be set.
+	RunContext func(ctx context.Context, cmd *Command, args []string) int
+
+	// PreRun, when set, is called before the run function, with the same
+	// arguments.  A non nil error is printed like a RunE error, and the
+	// command is not run.
+	PreRun func(cmd *Command, args []string) error
+
+	// PostRun, when set, is called after the run function, with the same
+	// arguments, even if the command failed, unless PreRun failed.  A non nil
+	// error is printed like a RunE error, and it sets the exit status if the
+	// command succeeded.
+	PostRun func(cmd *Command, args []string) error
+
+	// PersistentPreRun and PersistentPostRun are like PreRun and PostRun,
+	// but they are inherited by all the sub commands.  Only the nearest
+	// function is called: the one set on the command being run or, if not
+	// set, on its nearest parent, so that a sub command can override the
+	// function of a parent.  PersistentPreRun is called before PreRun and
+	// PersistentPostRun after PostRun.  The cmd argument is always the
+	// command being run.
+	PersistentPreRun  func(cmd *Command, args []string) error
+	PersistentPostRun func(cmd *Command, args []string) error
+
+	// Usage prints the command usage to os.Stderr.  If not specified the help
+	// renderer set by SetHelpRenderer will be used, that by default prints
+	// UsageLine, followed by the flag defaults and a list of available sub
+	// commands.
 	Usage func()
 
 	// Name is the command name.
@@ -56,25 +185,240 @@ type Command struct {
 	// usage template.
 	UsageLine string
 
-	// Short is the short description shown in the 'cmd -help' output.
+	// Short is the short description shown in the 'cmd -help' output.  If not
+	// specified the first line of Long will be used.
 	Short string
 
 	// Long is the long message shown in the command default usage output.
 	Long string
 
+	// Examples lists the usage examples shown after Long in the command
+	// default usage output.
+	Examples []Example
+
+	// Version is the program version.  When set on the main command, Parse
+	// defines the -version and -V flags, unless already defined, and Run
+	// prints "<name> version <Version>" to os.Stdout when one of them is
+	// set, without running any command.
+	Version string
+
 	// Flag is a set of flags specific to this command.
 	Flag flag.FlagSet
 
+	// PersistentFlag is a set of flags inherited by this command and all its
+	// sub commands.  Before parsing, Parse adds the persistent flags of a
+	// command and of its parents to Flag, unless Flag already defines a flag
+	// with the same name.  A persistent flag of the main command can be set
+	// both before and after the sub command name.
+	PersistentFlag flag.FlagSet
+
 	// CustomFlags indicates that the command will do its own flag parsing.
 	CustomFlags bool
 
+	// MinArgs and MaxArgs are the minimum and maximum number of arguments
+	// accepted by the command, after the flags.  A negative or 0 MaxArgs
+	// means no maximum.  When both are 0, the number of arguments is not
+	// checked.
+	// Run reports a usage error when the number of arguments is out of
+	// range.
+	MinArgs int
+	MaxArgs int
+
+	// Args, when set, validates the arguments of the command, after the
+	// flags.  Run calls Args after checking MinArgs and MaxArgs, and reports
+	// a non nil error as a usage error.  See ExactArgs, MinimumNArgs, NoArgs
+	// and OnlyValidArgs.
+	Args func(cmd *Command, args []string) error
+
+	// ValidArgs lists the valid arguments of the command, as checked by
+	// OnlyValidArgs.
+	ValidArgs []string
+
+	// PreParse, when set, is called by Parse with the argument list before
+	// any flag or command is matched, and returns the argument list to parse.
+	// It can be used to rewrite the arguments, e.g. to expand user defined
+	// aliases.  An error aborts parsing.  It is only used by the main
+	// command.
+	PreParse func(argv []string) ([]string, error)
+
+	// ExpandResponseFiles indicates that Parse will replace each argument
+	// starting with '@' with the arguments read from the named response file.
+	// It is only used by the main command.
+	ExpandResponseFiles bool
+
+	// FParseErrWhitelist configures the flag parsing errors to be ignored.
+	FParseErrWhitelist ParseErrorsWhitelist
+
+	// Virtual indicates that the command is only a grouping of sub commands,
+	// and that it is never runnable, even if Run is set.
+	Virtual bool
+
+	// Experimental indicates that the command is experimental and may change.
+	// A notice is printed to os.Stderr the first time the command is run, and
+	// the command is marked as experimental in the usage output.  The sub
+	// commands of an experimental command are experimental too.
+	Experimental bool
+
+	// SilenceExperimental suppresses the notice printed when an experimental
+	// command is run.
+	SilenceExperimental bool
+
+	// Hidden indicates that the command is not listed in the usage output of
+	// its parent, and is never suggested for an unknown command.  An hidden
+	// command is still matched by Parse.
+	Hidden bool
+
+	// Group is the heading the command is listed under in the usage output of
+	// its parent, like "remote commands".  When a sub command has a Group,
+	// the sub commands are listed by group, in the order each group is first
+	// declared, followed by the commands without a Group under the "other
+	// commands" heading.
+	Group string
+
+	// Deprecated, when not empty, indicates that the command is deprecated,
+	// and it is the message printed to os.Stderr each time the command is
+	// run, like "use 'app new' instead".  The command is marked as deprecated
+	// in the usage output.
+	Deprecated string
+
+	// SilenceDeprecation suppresses the message printed when a deprecated
+	// command is run.
+	SilenceDeprecation bool
+
 	// Commands lists the available commands.
 	// The order here is the order in which they are printed by 'cmd -help'.
 	// Note that subcommands are in general best avoided.
 	Commands []*Command
 
+	// Default is the name of the sub command invoked by Parse when no sub
+	// command is specified, instead of returning ErrNoCommand.  It is an
+	// error if no sub command is named Default.
+	Default string
+
+	// CaseInsensitive indicates that Parse matches the sub commands of this
+	// command, and of all its sub commands, ignoring case, when there is no
+	// exact match.  When more commands match, the first one in Commands
+	// wins, and then the first alias in lexical order.  The canonical name
+	// is still used in messages.
+	CaseInsensitive bool
+
+	// AllowPrefixMatch indicates that Parse matches the sub commands of this
+	// command, and of all its sub commands, by an unambiguous prefix of
+	// their name, like 'app sta' for 'app status', when there is no other
+	// match.  Parse returns an error listing the candidates when the prefix
+	// is ambiguous.  Hidden commands are never matched by prefix.
+	AllowPrefixMatch bool
+
+	// Footer is the message printed at the end of the command default usage
+	// output.  If not specified the footer set by SetUsageFooter will be
+	// used.
+	Footer string
+
+	// Topics lists the additional help topics.  Help topics are not runnable
+	// and are never matched by Parse.
+	Topics []*HelpTopic
+
 	// parent is the parent of this command.
 	parent *Command
+
+	// hiddenFlags is the set of flags marked as hidden by HideFlag.
+	hiddenFlags map[string]bool
+
+	// secretFlags is the set of flags marked as secret by MarkFlagSecret.
+	secretFlags map[string]bool
+
+	// envFlags maps the flags bound by BindEnv to the environment variable
+	// names.
+	envFlags map[string]string
+
+	// autoEnv indicates that AutoEnv has been called, with envPrefix.
+	autoEnv   bool
+	envPrefix string
+
+	// envSet is the set of flags set from the environment by the last
+	// parsing of c.
+	envSet map[string]bool
+
+	// getenv, when not nil, is the function used by the main command to read
+	// the environment, as set by DispatchEnv.
+	getenv func(key string) string
+
+	// requiredFlags is the set of flags marked as required by
+	// MarkFlagRequired.
+	requiredFlags map[string]bool
+
+	// exclusiveFlags lists the flag groups marked by
+	// MarkFlagsMutuallyExclusive.
+	exclusiveFlags [][]string
+
+	// togetherFlags lists the flag groups marked by
+	// MarkFlagsRequiredTogether.
+	togetherFlags [][]string
+
+	// defaultFuncs lists the functions set by SetFlagDefaultFunc.
+	defaultFuncs []defaultFunc
+
+	// resolving maps the flags with a default function to their resolution
+	// state, while the default values are being resolved.
+	resolving map[string]resolveState
+
+	// running indicates that the command is running.
+	running bool
+
+	// ctx is the context of the running command.
+	ctx context.Context
+
+	// noticed indicates that the experimental notice has been printed.
+	noticed bool
+
+	// aliases maps the aliases added by AliasCommand to their target.
+	aliases map[string]*Command
+
+	// version is the value of the flags defined for Version.
+	version *bool
+}
+
+// ParseErrorsWhitelist configures the flag parsing errors to be ignored.
+type ParseErrorsWhitelist struct {
+	// UnknownFlags will ignore unknown flags, keeping them in the argument
+	// list, before the remaining arguments.  Since the value of an unknown
+	// flag can not be detected, it should be specified as -flag=value.
+	// Ignoring unknown flags is only useful for commands without sub
+	// commands.
+	UnknownFlags bool
+}
+
+// An Example is a usage example of a command.
+type Example struct {
+	// Desc is the example description, shown as a comment above Command.
+	// It is optional.
+	Desc string
+
+	// Command is the example command line, like "app remote add origin".
+	Command string
+}
+
+// A HelpTopic is an additional help topic, like 'go help gopath'.
+type HelpTopic struct {
+	// Name is the topic name.
+	Name string
+
+	// Short is the short description shown in the 'cmd -help' output.  If not
+	// specified the first line of Long will be used.
+	Short string
+
+	// Long is the topic body.
+	Long string
+}
+
+// ShortDescription returns the topic's short description.  It returns Short
+// if set, otherwise the first non empty line of Long.
+func (t *HelpTopic) ShortDescription() string {
+	if t.Short != "" {
+		return t.Short
+	}
+
+	return firstLine(t.Long)
 }
 
 // LongName returns the command's long name.
@@ -91,9 +435,234 @@ func (c *Command) LongName() string {
 	return name
 }
 
+// AliasCommand adds alias as an alternate name for target, that can be
+// invoked as a sub command of c.  Unlike Name, target can be in any position
+// of the command tree.  When alias is invoked, Parse will resolve it to target,
+// including its sub commands, reporting target with its canonical name.
+// Regular sub commands take precedence over aliases.
+//
+// AliasCommand panics if target is c or one of its parents, since the command
+// tree would become a cycle.  Since the parents of c may not be known until
+// the command-line is parsed, Parse panics too when resolving such an alias.
+func (c *Command) AliasCommand(alias string, target *Command) {
+	c.checkAlias(alias, target)
+	if c.aliases == nil {
+		c.aliases = make(map[string]*Command)
+	}
+	c.aliases[alias] = target
+}
+
+// checkAlias panics if the target of alias is c or one of its parents.
+func (c *Command) checkAlias(alias string, target *Command) {
+	for p := c; p != nil; p = p.parent {
+		if p == target {
+			panic(fmt.Sprintf("cmd: alias %s of %s refers to %s, that is "+
+				"not a sub command", alias, c, target.Name))
+		}
+	}
+}
+
+// Invoke runs the sub command of c, or the sibling command of c if no such sub
+// command exists, invoked as name with the specified arguments, that are parsed
+// like Parse does.  It returns the status code returned by Command.Run or, in
+// case of parsing error, the exit status documented in Run.
+//
+// Invoke can be used to implement a command in terms of other commands.  A
+// command that is already being invoked can not be invoked again.
+func (c *Command) Invoke(name string, args ...string) int {
+	root := c.root()
+	owner := c
+	if owner.lookup(root, name) == nil && c.parent != nil {
+		owner = c.parent
+	}
+	if owner.lookup(root, name) == nil {
+		c.printUnknown(name, "unknown command")
+
+		return UnknownCommandCode
+	}
+	argv := append([]string{name}, args...)
+	cmd, err := parseCommands(root, owner, argv)
+	if err == errRecursiveInvocation {
+		cmd.printError(err)
+
+		return ExitFailure
+	}
+
+	return dispatch(c.Context(), root, cmd, err, root.Name)
+}
+
+// CheckErr does nothing if err is nil.  Otherwise it prints err to os.Stderr,
+// prefixed by the command name, sets the exit status returned by
+// ExitCodeFromError with cmdstate.SetExitStatus and exits with cmdstate.Exit,
+// so that the functions registered by cmdstate.AtExit are called.
+func (c *Command) CheckErr(err error) {
+	if err == nil {
+		return
+	}
+
+	c.printError(err)
+	cmdstate.SetExitStatus(ExitCodeFromError(err))
+	cmdstate.Exit()
+}
+
+// Context returns the context of the running command, as specified to
+// RunWithContext.  It returns context.Background() if the command is not
+// running.
+func (c *Command) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+
+	return c.ctx
+}
+
+// VisitParents calls fn for each parent of c, starting from the immediate
+// parent up to the main command.
+func (c *Command) VisitParents(fn func(*Command)) {
+	for cmd := c.parent; cmd != nil; cmd = cmd.parent {
+		fn(cmd)
+	}
+}
+
+// Walk calls fn for c and for each of its sub commands, depth first and in
+// declaration order, including the hidden commands.  The parent of each sub
+// command is set before calling fn, so that fn can use LongName.  Walk stops
+// and returns the first error returned by fn.
+func (c *Command) Walk(fn func(*Command) error) error {
+	if err := fn(c); err != nil {
+		return err
+	}
+	for _, cmd := range c.Commands {
+		cmd.parent = c
+		if err := cmd.Walk(fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Find returns the sub command of c with the specified path, like
+// Find("remote", "add").  Each element of path is matched like in Parse,
+// including the aliases added by AliasCommand, and the parents of the
+// returned command are set.  Find returns c if path is empty.  The error
+// wraps ErrUnknownCommand and names the first element that does not match.
+func (c *Command) Find(path ...string) (*Command, error) {
+	root := c.root()
+	cmd := c
+	for _, name := range path {
+		sub := cmd.lookup(root, name)
+		if sub == nil {
+			return nil, fmt.Errorf("%s %s: %w", cmd, name, ErrUnknownCommand)
+		}
+		cmd = sub
+	}
+
+	return cmd, nil
+}
+
+// root returns the main command of the command tree c belongs to.
+func (c *Command) root() *Command {
+	for c.parent != nil {
+		c = c.parent
+	}
+
+	return c
+}
+
+// experimentalAllowed indicates that experimental commands can be run.
+var experimentalAllowed = true
+
+// AllowExperimental sets whether experimental commands can be run.  It can be
+// used to require an explicit opt-in, like an environment variable or a flag,
+// before experimental commands are run.  By default experimental commands are
+// allowed.
+func AllowExperimental(allow bool) {
+	experimentalAllowed = allow
+}
+
+// isExperimental reports whether c or one of its parents is experimental.
+func (c *Command) isExperimental() bool {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if cmd.Experimental {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResetCommands removes all the sub commands of c, including the aliases added
+// by AliasCommand, and clears their parent.  It does not change the flags of
+// c.
+func (c *Command) ResetCommands() {
+	for _, cmd := range c.Commands {
+		cmd.parent = nil
+	}
+	c.Commands = nil
+	c.aliases = nil
+}
+
 // Runnable reports whether the command can be run.
 func (c *Command) Runnable() bool {
-	return c.Run != nil
+	runnable := c.Run != nil || c.RunE != nil || c.RunContext != nil
+
+	return runnable && !c.Virtual
+}
+
+// HasParent reports whether c has a parent command.  The parent is only known
+// after c has been resolved by Parse.
+func (c *Command) HasParent() bool {
+	return c.parent != nil
+}
+
+// HasSubCommands reports whether c has sub commands that are listed in the
+// usage message, that is sub commands that are not hidden.
+func (c *Command) HasSubCommands() bool {
+	for _, cmd := range c.Commands {
+		if !cmd.Hidden {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ShortDescription returns the command's short description.  It returns Short
+// if set, otherwise the first non empty line of Long.
+func (c *Command) ShortDescription() string {
+	if c.Short != "" {
+		return c.Short
+	}
+
+	return firstLine(c.Long)
+}
+
+// firstLine returns the first non empty line of s, with leading and trailing
+// white space removed.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+
+	return ""
+}
+
+// listDescription returns the description of c in the usage output of its
+// parent, that is the short description annotated when c is experimental or
+// deprecated.
+func (c *Command) listDescription() string {
+	short := c.ShortDescription()
+	if c.Experimental {
+		short = strings.TrimSpace(short + " (experimental)")
+	}
+	if c.Deprecated != "" {
+		short = strings.TrimSpace(short + " (deprecated)")
+	}
+
+	return short
 }
 
 // String implements the Stringer interface.
@@ -107,23 +676,136 @@ func (c *Command) String() string {
 	return name
 }
 
-// defaultUsage prints a usage message documenting all defined command-line
-// flags and sub commands to os.Stderr.
-func (c *Command) defaultUsage() {
-	printf("usage: %s %s\n", c, c.UsageLine)
-	c.Flag.PrintDefaults()
+// defaultUsage writes to w a usage message documenting all defined
+// command-line flags, sub commands and help topics.
+func (c *Command) defaultUsage(w io.Writer) error {
+	if usageTemplate != "" {
+		return c.templateUsage(w)
+	}
+
+	p := palette{useColor(w)}
+	width := outputWidth(w)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "usage: %s %s\n", p.bold(c.String()), c.UsageLine)
+	c.printFlags(&b, false, width)
+	if c.showHiddenFlags() {
+		fmt.Fprint(&b, "\ndebug flags:\n")
+		c.printFlags(&b, true, width)
+	}
 	if c.Long != "" {
-		printf("\n%s\n", c.Long)
+		fmt.Fprintf(&b, "\n%s\n", wrap(c.Long, width))
+	}
+	if len(c.Examples) > 0 {
+		fmt.Fprint(&b, "\nexamples:\n")
+		for _, example := range c.Examples {
+			fmt.Fprint(&b, "\n")
+			if example.Desc != "" {
+				fmt.Fprintf(&b, "\t# %s\n", example.Desc)
+			}
+			fmt.Fprintf(&b, "\t%s\n", example.Command)
+		}
+	}
+
+	width = c.nameWidth()
+	for _, group := range commandGroups(visibleCommands(c)) {
+		fmt.Fprintf(&b, "\n%s:\n\n", group.Heading)
+		for _, cmd := range group.Commands {
+			b.WriteString(p.row(cmd.Name, cmd.listDescription(), width))
+		}
 	}
 
-	if len(c.Commands) > 0 {
-		print("\ncommands:\n\n")
-		for _, cmd := range c.Commands {
-			printf("\t%-11s %s\n", cmd.Name, cmd.Short)
+	if len(c.Topics) > 0 {
+		fmt.Fprint(&b, "\nadditional help topics:\n\n")
+		for _, topic := range c.Topics {
+			b.WriteString(p.row(topic.Name, topic.ShortDescription(), width))
 		}
 	}
+
+	if footer := c.footer(); footer != "" {
+		fmt.Fprintf(&b, "\n%s\n", footer)
+	}
+
+	_, err := w.Write(b.Bytes())
+
+	return err
+}
+
+// A commandGroup is a list of commands sharing the same heading in the default
+// usage output.
+type commandGroup struct {
+	Heading  string
+	Commands []*Command
 }
 
+// commandGroups returns the commands in list grouped as documented in
+// Command.Group.  When no command has a Group, all the commands are listed
+// under the "commands" heading.
+func commandGroups(list []*Command) []commandGroup {
+	var groups []commandGroup
+	var other []*Command
+	index := make(map[string]int) // group name to index in groups
+	for _, cmd := range list {
+		if cmd.Group == "" {
+			other = append(other, cmd)
+
+			continue
+		}
+		i, ok := index[cmd.Group]
+		if !ok {
+			i = len(groups)
+			index[cmd.Group] = i
+			groups = append(groups, commandGroup{Heading: cmd.Group})
+		}
+		groups[i].Commands = append(groups[i].Commands, cmd)
+	}
+	if len(other) > 0 {
+		heading := "other commands"
+		if len(groups) == 0 {
+			heading = "commands"
+		}
+		groups = append(groups, commandGroup{heading, other})
+	}
+
+	return groups
+}
+
+// maxNameWidth is the maximum width of the name column in the default usage
+// output.  Longer names are not padded.
+const maxNameWidth = 24
+
+// nameWidth returns the width of the name column in the default usage output
+// of c, that is the length of the longest name of its visible sub commands
+// and help topics, up to maxNameWidth.
+func (c *Command) nameWidth() int {
+	width := 0
+	for _, cmd := range visibleCommands(c) {
+		if n := len(cmd.Name); n > width {
+			width = n
+		}
+	}
+	for _, topic := range c.Topics {
+		if n := len(topic.Name); n > width {
+			width = n
+		}
+	}
+	if width > maxNameWidth {
+		width = maxNameWidth
+	}
+
+	return width
+}
+
+// footer returns the usage footer of the command.
+func (c *Command) footer() string {
+	if c.Footer != "" {
+		return c.Footer
+	}
+
+	return usageFooter
+}
+
+// usage prints the command usage to os.Stderr, using c.Usage if set or the
+// current help renderer.
 func (c *Command) usage() {
 	if c.Usage != nil {
 		c.Usage()
@@ -131,7 +813,16 @@ func (c *Command) usage() {
 		return
 	}
 
-	c.defaultUsage()
+	if err := c.WriteUsage(cmdstate.Output()); err != nil {
+		c.printError(fmt.Errorf("usage: %v", err))
+	}
+}
+
+// WriteUsage writes the command usage to w, using the help renderer set by
+// SetHelpRenderer.  Note that the Usage function is not used, since it always
+// prints to cmdstate.Output.
+func (c *Command) WriteUsage(w io.Writer) error {
+	return helpRenderer.Render(c, w)
 }
 
 // Parse parses command-line from argument list, which should not include the
@@ -143,41 +834,108 @@ func (c *Command) usage() {
 // main command except when the first flag is -help or -h.  This behavior is
 // like `git --no-pager diff` but unlike `git --version diff`.
 //
+// The "--" terminator stops the flag parsing and the sub command lookup at the
+// command where it is found, and the arguments after it are returned verbatim
+// by the command Flag.Args, even if they start with '-' or '@' or are the name
+// of a sub command.
+//
 // Parse must be called after all flags in main commands Flag are defined and
 // before flags are accessed by the program.  The return value will be
-// flag.ErrHelp if -help or -h were set but not defined.
+// flag.ErrHelp if -help or -h were set but not defined, and ErrVersion if
+// -version or -V were set on a main command with Version set.
 func Parse(main *Command, argv []string) (*Command, error) {
 	// Configure main.Flag so that errors and output are in our control, but
 	// restore the output when returning, since Command.defaultUsage will
 	// require it.
 	defer configure(main)()
-	if err := main.Flag.Parse(argv); err != nil {
+	main.mergePersistentFlags()
+	main.defineVersionFlags()
+	if main.PreParse != nil {
+		var err error
+		if argv, err = main.PreParse(argv); err != nil {
+			return main, err
+		}
+	}
+	if main.ExpandResponseFiles {
+		var err error
+		if argv, err = expandResponseFiles(main, argv); err != nil {
+			return main, err
+		}
+	}
+	if err := main.parseFlags(argv); err != nil {
 		return main, err
 	}
+	if main.version != nil && *main.version {
+		return main, ErrVersion
+	}
+	if main.terminated(argv) {
+		return main, nil
+	}
 
 	args := main.Flag.Args()
 	if len(args) < 1 {
-		return main, ErrNoCommand
+		var err error
+		if args, err = main.defaultCommand(main); err != nil {
+			return main, err
+		}
 	}
 
-MainLoop:
-	for _, cmd := range main.Commands {
-		if cmd.Name != args[0] {
-			continue
+	return parseCommands(main, main, args)
+}
+
+// defineVersionFlags defines the -version and -V flags of c, if c.Version is
+// set and the flags are not already defined.
+func (c *Command) defineVersionFlags() {
+	if c.Version == "" {
+		return
+	}
+	if c.version == nil {
+		c.version = new(bool)
+		for _, name := range []string{"version", "V"} {
+			if c.Flag.Lookup(name) == nil {
+				c.Flag.BoolVar(c.version, name, false, "print version and exit")
+			}
+		}
+	}
+	*c.version = false // c may have been parsed before
+}
+
+// parseCommands parses the sub commands of main, with root being the main
+// command of the command tree.  The first argument must be the name of the
+// sub command.
+func parseCommands(root, main *Command, args []string) (*Command, error) {
+	for {
+		cmd := main.lookup(root, args[0])
+		if cmd == nil {
+			var err error
+			if cmd, err = main.lookupPrefix(args[0]); err != nil {
+				return main, err
+			}
+		}
+		if cmd == nil {
+			return main, ErrUnknownCommand
+		}
+		if cmd.running {
+			// Parsing the flags again would change the flag values of the
+			// running command.
+			return cmd, errRecursiveInvocation
 		}
-		cmd.parent = main
 
 		// Configure cmd.Flag as it was done with main.Flag.
 		defer configure(cmd)()
+		cmd.mergePersistentFlags()
 		if cmd.CustomFlags {
 			// Prepend the "--" terminator to the argument list of the
 			// sub-command, so that Flag.Parse will treat flags as regular
 			// arguments.
 			args = append([]string{"", "--"}, args[1:]...)
 		}
-		if err := cmd.Flag.Parse(args[1:]); err != nil {
+		if err := cmd.parseFlags(args[1:]); err != nil {
 			return cmd, err
 		}
+		if !cmd.CustomFlags && cmd.terminated(args[1:]) {
+			return cmd, nil
+		}
 		args = cmd.Flag.Args()
 
 		// Process sub commands after parsing the flags.
@@ -186,74 +944,467 @@ MainLoop:
 		// cmd.Flag.Parse instead of being handled as regular arguments,
 		// resulting in an empty cmd.Flag.Args that will in turn cause Run to
 		// panic when handling ErrUnknownCommand.
-		if len(cmd.Commands) > 0 {
-			if len(args) == 0 {
-				return cmd, ErrNoCommand
+		if len(cmd.Commands) == 0 {
+			return cmd, nil
+		}
+		if len(args) == 0 {
+			var err error
+			if args, err = cmd.defaultCommand(root); err != nil {
+				return cmd, err
 			}
-			main = cmd
+		}
+		main = cmd
+	}
+}
 
-			goto MainLoop
+// defaultCommand returns the argument list invoking the default sub command of
+// c, with root being the main command of the command tree.  It returns
+// ErrNoCommand if c has no default sub command.
+func (c *Command) defaultCommand(root *Command) ([]string, error) {
+	if c.Default == "" {
+		return nil, ErrNoCommand
+	}
+	if c.lookup(root, c.Default) == nil {
+		return nil, fmt.Errorf("default command %q not defined", c.Default)
+	}
+
+	return []string{c.Default}, nil
+}
+
+// lookup returns the sub command of c invoked as name, or nil if no such
+// command exists.  The parent of the returned command is set, using root to
+// find the canonical parent of an aliased command.
+func (c *Command) lookup(root *Command, name string) *Command {
+	equal := func(s, t string) bool { return s == t }
+	if cmd := c.match(root, name, equal); cmd != nil {
+		return cmd
+	}
+	if c.inherits(func(c *Command) bool { return c.CaseInsensitive }) {
+		return c.match(root, name, strings.EqualFold)
+	}
+
+	return nil
+}
+
+// lookupPrefix returns the visible sub command of c whose name starts with
+// prefix, if prefix matching is allowed.  It returns nil if no such command
+// exists, and an error if more commands match.
+func (c *Command) lookupPrefix(prefix string) (*Command, error) {
+	if !c.inherits(func(c *Command) bool { return c.AllowPrefixMatch }) {
+		return nil, nil
+	}
+
+	var matches []*Command
+	var names []string
+	for _, cmd := range visibleCommands(c) {
+		if strings.HasPrefix(cmd.Name, prefix) {
+			matches = append(matches, cmd)
+			names = append(names, cmd.Name)
 		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		matches[0].parent = c
 
-		return cmd, nil
+		return matches[0], nil
 	}
 
-	return main, ErrUnknownCommand
+	return nil, fmt.Errorf("ambiguous command %q, could be %s", prefix,
+		strings.Join(names, ", "))
+}
+
+// match returns the first sub command of c whose name, or alias, is equal to
+// name, as reported by the equal function.  Names take precedence over
+// aliases.  The parent of the returned command is set as documented in
+// lookup.
+func (c *Command) match(root *Command, name string,
+	equal func(s, t string) bool) *Command {
+	for _, cmd := range c.Commands {
+		if equal(cmd.Name, name) {
+			cmd.parent = c
+
+			return cmd
+		}
+	}
+
+	// Sort the aliases, so that the match is deterministic.
+	aliases := make([]string, 0, len(c.aliases))
+	for alias := range c.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		if !equal(alias, name) {
+			continue
+		}
+		target := c.aliases[alias]
+		c.checkAlias(alias, target)
+		if !setParents(root, target) {
+			target.parent = c // target is not in the command tree
+		}
+
+		return target
+	}
+
+	return nil
+}
+
+// inherits reports whether f is true for c or one of its parents.
+func (c *Command) inherits(f func(*Command) bool) bool {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if f(cmd) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setParents sets the parent of all the commands in the path from c to
+// target, reporting whether target is a descendant of c.
+func setParents(c, target *Command) bool {
+	for _, cmd := range c.Commands {
+		if cmd == target || setParents(cmd, target) {
+			cmd.parent = c
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseFlags parses the flags from the argument list, honoring
+// c.FParseErrWhitelist, and then sets the flags bound to the environment and
+// the dependent default values.
+func (c *Command) parseFlags(args []string) error {
+	if err := c.parseArgs(args); err != nil {
+		return err
+	}
+	if err := c.applyEnv(); err != nil {
+		return err
+	}
+
+	return c.resolveDefaults()
+}
+
+// parseArgs parses the flags from the argument list, honoring
+// c.FParseErrWhitelist.
+func (c *Command) parseArgs(args []string) error {
+	if !c.FParseErrWhitelist.UnknownFlags {
+		return c.Flag.Parse(args)
+	}
+
+	var unknown []string
+	for {
+		err := c.Flag.Parse(args)
+		if err == nil {
+			break
+		}
+		if !isUnknownFlag(err) {
+			return err
+		}
+
+		// The unknown flag is the last argument consumed by Flag.Parse.
+		rest := c.Flag.Args()
+		unknown = append(unknown, args[len(args)-len(rest)-1])
+		args = rest
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	// Parse again the arguments, so that the unknown flags are returned by
+	// Flag.Args.
+	args = append(unknown, c.Flag.Args()...)
+
+	return c.Flag.Parse(append([]string{"--"}, args...))
+}
+
+// terminated reports whether the flags of c at the start of args end with the
+// "--" terminator, instead of with the first non-flag argument.
+func (c *Command) terminated(args []string) bool {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--":
+			return true
+		case len(arg) < 2 || arg[0] != '-':
+			return false
+		case flagValue(arg, c.Flag.Lookup):
+			i++ // skip the flag value
+		}
+	}
+
+	return false
+}
+
+// flagValue reports whether arg is a flag, found by lookup, whose value is the
+// next argument.
+func flagValue(arg string, lookup func(name string) *flag.Flag) bool {
+	if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+		return false
+	}
+	name := strings.TrimPrefix(arg[1:], "-")
+	if strings.Contains(name, "=") {
+		return false
+	}
+	f := lookup(name)
+
+	return f != nil && !isBoolFlag(f)
+}
+
+// isUnknownFlag reports whether err is the error returned by flag.FlagSet.Parse
+// when a flag is not defined.
+func isUnknownFlag(err error) bool {
+	return strings.HasPrefix(err.Error(), "flag provided but not defined: ")
 }
 
 // configure configures c so that c.Flag error handling is set to continue on
-// errors and its output is temporarily disabled.  Calling the returned restore
-// function will restore C.Flag.Output to os.Stderr and set c.Flag.Usage to
-// c.usage.
+// errors and its output and usage are temporarily disabled.  Calling the
+// returned restore function will restore C.Flag.Output to cmdstate.Output and
+// set c.Flag.Usage to c.usage.
 //
 // configure assumes that c.Flag has not been modified, so that c.Flag.Output()
-// is os.Stderr and c.Flag.Usage is nil.
+// is os.Stderr and c.Flag.Usage is nil or c.usage.
 func configure(c *Command) (restore func()) {
 	c.Flag.Init(c.String(), flag.ContinueOnError)
 	c.Flag.SetOutput(ioutil.Discard)
+	c.Flag.Usage = func() {} // c may have been parsed before
 
 	return func() {
 		c.Flag.Usage = c.usage // this is not really necessary
-		c.Flag.SetOutput(nil)
+		c.Flag.SetOutput(cmdstate.Output())
 	}
 }
 
+// printError prints err to os.Stderr, prefixed by the error prefix set by
+// cmdstate.SetErrorPrefix or, when not set, by the command name.
+func (c *Command) printError(err error) {
+	prefix := cmdstate.ErrorPrefix()
+	if prefix == "" {
+		prefix = c.String() + ": "
+	}
+	printf("%s%v\n", prefix, err)
+}
+
+// printUnknown prints msg for the unknown sub command name of c, using the
+// prefix set by cmdstate.SetErrorPrefix or, when not set, the full name of c
+// followed by name.
+func (c *Command) printUnknown(name, msg string) {
+	if cmdstate.ErrorPrefix() == "" {
+		printf("%s %s: %s\n", c, name, msg)
+
+		return
+	}
+	c.printError(fmt.Errorf("%s: %s", name, msg))
+}
+
 func print(args ...interface{}) {
-	fmt.Fprint(os.Stderr, args...)
+	fmt.Fprint(cmdstate.Output(), args...)
 }
 
 func printf(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, format, args...)
+	fmt.Fprintf(cmdstate.Output(), format, args...)
 }
 
 // Run parses the command-line from os.Args[1:] and execute the appropriate
-// sub command of main.  It returns the status code returned by Command.Run or
-// ExitUsageError in case of parsing error.
+// sub command of main.  It returns the status code returned by Command.Run or,
+// in case of parsing error, the exit status for the error category as set by
+// FlagErrorCode, UnknownCommandCode and NoCommandCode.  The exit status for
+// -help is always ExitUsageError.
+//
+// Run is equivalent to RunWithContext(context.Background(), main).
 func Run(main *Command) int {
-	cmd, err := Parse(main, os.Args[1:])
-	osname := os.Args[0] // follow UNIX cmd -h convention
+	return RunWithContext(context.Background(), main)
+}
+
+// RunWithContext is like Run, but ctx is passed to the Command.RunContext
+// function and returned by Command.Context while the command is running.
+func RunWithContext(ctx context.Context, main *Command) int {
+	record(os.Args)
+
+	return runContext(ctx, main, os.Args)
+}
+
+// run is like Run, but parses the command-line from argv[1:] and uses argv[0]
+// as the main command name in messages.
+func run(main *Command, argv []string) int {
+	return runContext(context.Background(), main, argv)
+}
+
+// runContext is like run, with the specified context.
+func runContext(ctx context.Context, main *Command, argv []string) int {
+	cmd, err := Parse(main, argv[1:])
+
+	return dispatch(ctx, main, cmd, err, argv[0]) // follow UNIX cmd -h convention
+}
+
+// dispatch handles the result of parsing the command-line of main, and runs
+// cmd if there were no errors.  It returns the status code returned by
+// Command.Run or the exit status returned by parseErrorCode in case of parsing
+// error.  The main command name is changed to osname when printing messages.
+func dispatch(ctx context.Context, main, cmd *Command, err error,
+	osname string) int {
 	args := cmd.Flag.Args()
 	switch {
 	case err == ErrUnknownCommand:
 		main.Name = osname
-		printf("%s %s: unknown command\n", cmd, args[0])
-		printf("Run '%s -help' for usage.\n", cmd)
+		cmd.printUnknown(args[0], "unknown command")
+		if names := Suggestions(args[0], cmd.Commands); len(names) > 0 {
+			print("\nDid you mean this?\n")
+			for _, name := range names {
+				printf("\t%s\n", name)
+			}
+			if cmd == main {
+				print("\n")
+			}
+		}
+		if cmd == main {
+			printf("Run '%s -help' for usage.\n", cmd)
+
+			break
+		}
+
+		// Show the sub commands of the deepest matched command.
+		print("\n")
+		cmd.usage()
 	case err == flag.ErrHelp:
 		main.Name = osname
 		cmd.usage()
+	case err == ErrVersion:
+		main.Name = osname
+		fmt.Fprintf(os.Stdout, "%s version %s\n", main, main.Version)
+
+		return ExitSuccess
 	case err != nil:
 		main.Name = osname
-		printf("%s: %v\n", cmd, err)
+		cmd.printError(err)
 		cmd.usage()
 	}
 	if err != nil {
-		return ExitUsageError
+		return parseErrorCode(err)
 	}
 	if !cmd.Runnable() {
-		printf("%s: not runnable\n", cmd)
+		if cmd.Virtual {
+			main.Name = osname
+			cmd.usage()
+
+			return ExitUsageError
+		}
+		cmd.printError(errors.New("not runnable"))
+
+		return ExitUsageError
+	}
+	err = cmd.checkFlags()
+	if err == nil {
+		err = cmd.checkArgs(args)
+	}
+	if err != nil {
+		main.Name = osname
+		cmd.printError(err)
+		cmd.usage()
 
 		return ExitUsageError
 	}
 
-	return cmd.Run(cmd, args)
+	if cmd.isExperimental() {
+		if !experimentalAllowed {
+			cmd.printError(errors.New("experimental command not allowed"))
+
+			return ExitUsageError
+		}
+		if !cmd.SilenceExperimental && !cmd.noticed {
+			printf("%s: this command is experimental and may change\n", cmd)
+			cmd.noticed = true
+		}
+	}
+
+	if cmd.Deprecated != "" && !cmd.SilenceDeprecation {
+		printf("command %q is deprecated: %s\n", cmd.Name, cmd.Deprecated)
+	}
+
+	cmd.running = true
+	cmd.ctx = ctx
+	prev := setCurrent(cmd)
+	defer func() {
+		cmd.running = false
+		cmd.ctx = nil
+		setCurrent(prev)
+	}()
+
+	return cmd.execute(args)
+}
+
+// execute calls c.Run, c.RunE or c.RunContext with the specified arguments,
+// between the pre run and post run hooks, returning the exit status.
+func (c *Command) execute(args []string) int {
+	n := 0
+	for _, set := range []bool{c.Run != nil, c.RunE != nil, c.RunContext != nil} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		c.printError(errors.New("only one of Run, RunE and RunContext can " +
+			"be set"))
+
+		return ExitFailure
+	}
+
+	pre := c.PersistentPreRun
+	post := c.PersistentPostRun
+	c.VisitParents(func(p *Command) {
+		if pre == nil {
+			pre = p.PersistentPreRun
+		}
+		if post == nil {
+			post = p.PersistentPostRun
+		}
+	})
+
+	for _, hook := range []func(*Command, []string) error{pre, c.PreRun} {
+		if hook == nil {
+			continue
+		}
+		if err := hook(c, args); err != nil {
+			c.printError(err)
+
+			return ExitCodeFromError(err)
+		}
+	}
+	status := c.call(args)
+	for _, hook := range []func(*Command, []string) error{c.PostRun, post} {
+		if hook == nil {
+			continue
+		}
+		if err := hook(c, args); err != nil {
+			c.printError(err)
+			if status == ExitSuccess {
+				status = ExitCodeFromError(err)
+			}
+		}
+	}
+
+	return status
+}
+
+// call calls the run function of c with the specified arguments, returning the
+// exit status.
+func (c *Command) call(args []string) int {
+	switch {
+	case c.Run != nil:
+		return c.Run(c, args)
+	case c.RunContext != nil:
+		return c.RunContext(c.Context(), c, args)
+	}
+
+	if err := c.RunE(c, args); err != nil {
+		c.printError(err)
+
+		return ExitCodeFromError(err)
+	}
+
+	return ExitSuccess
 }
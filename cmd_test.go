@@ -5,9 +5,19 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/perillo/cmd/cmdstate"
 )
 
 type list = []string
@@ -58,12 +68,314 @@ func TestCommandString(t *testing.T) {
 	}
 }
 
+// TestCommandVisitParents tests the Command.VisitParents method.
+func TestCommandVisitParents(t *testing.T) {
+	var tests = []struct {
+		names list
+		want  list
+	}{
+		{list{"test"}, nil},
+		{list{"test", "cmd"}, list{"test"}},
+		{list{"test", "cmd", "a"}, list{"cmd", "test"}},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.names)), func(t *testing.T) {
+			cmd := buildp(test.names)
+			var got list
+			cmd.VisitParents(func(c *Command) {
+				got = append(got, c.Name)
+			})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCommandWalk tests that Command.Walk visits the command tree depth first,
+// with the parents set, and stops at the first error.
+func TestCommandWalk(t *testing.T) {
+	main := &Command{
+		Name: "test",
+		Commands: []*Command{
+			{
+				Name: "remote",
+				Commands: []*Command{
+					{Name: "add"},
+					{Name: "remove", Hidden: true},
+				},
+			},
+			{Name: "status"},
+		},
+	}
+
+	var got list
+	err := main.Walk(func(c *Command) error {
+		got = append(got, c.LongName())
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := list{"", "remote", "remote add", "remote remove", "status"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	errStop := errors.New("stop")
+	got = nil
+	err = main.Walk(func(c *Command) error {
+		got = append(got, c.Name)
+		if c.Name == "add" {
+			return errStop
+		}
+
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("got error %v, want %v", err, errStop)
+	}
+	if want := (list{"test", "remote", "add"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestCommandFind tests that Command.Find resolves a command by path,
+// including aliases, and names the first unmatched element on error.
+func TestCommandFind(t *testing.T) {
+	var tests = []struct {
+		path list
+		want string // expected command
+		err  string // expected error
+	}{
+		{nil, "test", ""},
+		{list{"cmd1"}, "test cmd1", ""},
+		{list{"cmd1", "cmd2"}, "test cmd1 cmd2", ""},
+		{list{"c2"}, "test cmd1 cmd2", ""},
+		{list{"cmd1", "x", "cmd2"}, "", "test cmd1 x: unknown command"},
+		{list{"x"}, "", "test x: unknown command"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.path)), func(t *testing.T) {
+			main := build(list{"test", "cmd1", "cmd2"})
+			main.AliasCommand("c2", main.Commands[0].Commands[0])
+
+			cmd, err := main.Find(test.path...)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				if !errors.Is(err, ErrUnknownCommand) {
+					t.Errorf("error %v does not wrap %v", err,
+						ErrUnknownCommand)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := cmd.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCommandResetCommands tests that a command tree can be built again after
+// calling Command.ResetCommands.
+func TestCommandResetCommands(t *testing.T) {
+	main := build(list{"test", "a"})
+	a := main.Commands[0]
+	main.AliasCommand("x", a)
+	flag := main.Flag.Bool("flag", false, "flag")
+	if _, err := Parse(main, list{"a"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	main.ResetCommands()
+	if len(main.Commands) != 0 {
+		t.Errorf("got %d commands, want 0", len(main.Commands))
+	}
+	if a.String() != "a" {
+		t.Errorf("got %q, want %q (parent not cleared)", a.String(), "a")
+	}
+	for _, name := range []string{"a", "x"} {
+		if _, err := Parse(main, list{name}); err != ErrUnknownCommand {
+			t.Errorf("got error %v, want %v", err, ErrUnknownCommand)
+		}
+	}
+
+	// Rebuild the tree.
+	main.Commands = []*Command{{Name: "b"}}
+	cmd, err := Parse(main, list{"-flag", "b"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if cmd.String() != "test b" {
+		t.Errorf("got %q, want %q", cmd.String(), "test b")
+	}
+	if !*flag {
+		t.Errorf("flag not set")
+	}
+}
+
+// TestCommandHasParent tests the Command.HasParent method.
+func TestCommandHasParent(t *testing.T) {
+	var tests = []struct {
+		names list
+		want  bool
+	}{
+		{list{"test"}, false},
+		{list{"test", "cmd"}, true},
+		{list{"test", "cmd", "a"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.names)), func(t *testing.T) {
+			cmd := buildp(test.names)
+			if got := cmd.HasParent(); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCommandHasSubCommands tests the Command.HasSubCommands method.
+func TestCommandHasSubCommands(t *testing.T) {
+	var tests = []struct {
+		names list
+		want  bool
+	}{
+		{list{"test"}, false},
+		{list{"test", "cmd"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.names)), func(t *testing.T) {
+			cmd := build(test.names)
+			if got := cmd.HasSubCommands(); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCommandShortDescription tests the Command.ShortDescription method.
+func TestCommandShortDescription(t *testing.T) {
+	var tests = []struct {
+		short string
+		long  string
+		want  string
+	}{
+		{"", "", ""},
+		{"short", "", "short"},
+		{"short", "long", "short"},
+		{"", "long", "long"},
+		{"", "first line\nsecond line", "first line"},
+		{"", "\n  first line  \nsecond line", "first line"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.want), func(t *testing.T) {
+			cmd := &Command{Short: test.short, Long: test.long}
+			got := cmd.ShortDescription()
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// exitError is an error implementing ExitCoder.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func (e exitError) ExitCode() int {
+	return int(e)
+}
+
+// TestExitCodeFromError tests the ExitCodeFromError function.
+func TestExitCodeFromError(t *testing.T) {
+	var tests = []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitSuccess},
+		{errors.New("error"), ExitFailure},
+		{exitError(3), 3},
+		{exitError(-1), ExitFailure},
+		{fmt.Errorf("wrapped: %w", exitError(4)), 4},
+		{context.DeadlineExceeded, ExitTimeout},
+		{fmt.Errorf("wrapped: %w", context.Canceled), ExitInterrupt},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(fmt.Sprint(test.err)), func(t *testing.T) {
+			got := ExitCodeFromError(test.err)
+			if got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCommandCheckErr tests the Command.CheckErr method.  Since CheckErr
+// exits, each test is run in a new process executing the test binary.
+func TestCommandCheckErr(t *testing.T) {
+	const key = "CMD_TEST_CHECKERR"
+
+	var tests = []struct {
+		err    error
+		status int    // expected exit status
+		want   string // expected output
+	}{
+		{nil, ExitSuccess, ""},
+		{errors.New("error"), ExitFailure, "test cmd: error\ncleanup\n"},
+		{exitError(3), 3, "test cmd: exit status 3\ncleanup\n"},
+	}
+
+	if s := os.Getenv(key); s != "" {
+		i, _ := strconv.Atoi(s)
+		cmdstate.AtExit(func() { fmt.Println("cleanup") })
+		buildp(list{"test", "cmd"}).CheckErr(tests[i].err)
+		os.Exit(ExitSuccess) // CheckErr did not exit
+	}
+
+	for i, test := range tests {
+		t.Run(mkname(fmt.Sprint(test.err)), func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestCommandCheckErr$")
+			cmd.Env = append(os.Environ(), key+"="+strconv.Itoa(i))
+			out, err := cmd.CombinedOutput()
+			status := ExitSuccess
+			if e, ok := err.(*exec.ExitError); ok {
+				status = e.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if status != test.status {
+				t.Errorf("got exit status %d, want %d", status, test.status)
+			}
+			if output := string(out); output != test.want {
+				t.Errorf("got output %q, want %q", output, test.want)
+			}
+		})
+	}
+}
+
 // TestParse tests the Parse function.
 func TestParse(t *testing.T) {
 	// Define variables to keep the test entries short.
 	cmd0 := list{"test"}
 	cmd1 := list{"test", "cmd"}
 	cmd2 := list{"test", "cmd1", "cmd2"}
+	cmd3 := list{"test", "cmd1", "cmd2", "cmd3"}
 
 	var tests = []struct {
 		names list
@@ -81,6 +393,10 @@ func TestParse(t *testing.T) {
 		{cmd2, list{"test", "cmd1", "cmd2"}, "cmd2", nil},
 		{cmd2, list{"test", "cmd1", "a"}, "cmd1", ErrUnknownCommand},
 		{cmd2, list{"test", "cmd1", "cmd2", "-h"}, "cmd2", ErrHelp},
+
+		{cmd3, list{"test", "cmd1", "cmd2"}, "cmd2", ErrNoCommand},
+		{cmd3, list{"test", "cmd1", "cmd2", "cmd3"}, "cmd3", nil},
+		{cmd3, list{"test", "cmd1", "cmd2", "a"}, "cmd2", ErrUnknownCommand},
 	}
 
 	for _, test := range tests {
@@ -95,10 +411,700 @@ func TestParse(t *testing.T) {
 			if cmd.Name != test.cmd {
 				t.Errorf("got command %q, want %q", cmd.Name, test.cmd)
 			}
+
+			// Test that the parent of each matched command is set.
+			if want := join(test.argv); err == nil && cmd.String() != want {
+				t.Errorf("got full name %q, want %q", cmd.String(), want)
+			}
+		})
+	}
+}
+
+// TestParseAliasCommand tests the Parse function, with a command alias added by
+// AliasCommand.
+func TestParseAliasCommand(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want string // expected full command name
+		err  error  // expected error
+	}{
+		{list{"test", "co"}, "test checkout", ErrNoCommand},
+		{list{"test", "co", "branch"}, "test checkout branch", nil},
+		{list{"test", "co", "a"}, "test checkout", ErrUnknownCommand},
+		{list{"test", "radd"}, "test remote add", nil},
+		{list{"test", "remote", "radd"}, "test remote", ErrUnknownCommand},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := build(list{"test", "checkout", "branch"})
+			remote := build(list{"remote", "add"})
+			main.Commands = append(main.Commands, remote)
+			main.AliasCommand("co", main.Commands[0])
+			main.AliasCommand("radd", remote.Commands[0])
+
+			cmd, err := Parse(main, test.argv[1:])
+			if err != test.err {
+				t.Errorf("got error %v, want %v", err, test.err)
+			}
+			if got := cmd.String(); got != test.want {
+				t.Errorf("got command %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestAliasCommandCycle tests that AliasCommand and Parse panic when the
+// target of an alias is the command or one of its parents.
+func TestAliasCommandCycle(t *testing.T) {
+	var tests = []struct {
+		name   string
+		define bool // the parents of remote are known when defining the alias
+		target int  // depth of the target on the remote path
+	}{
+		{"self", true, 1},
+		{"parent", true, 0},
+		{"self-parse", false, 1},
+		{"parent-parse", false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			main := build(list{"app", "remote", "add"})
+			remote := main.Commands[0]
+			if test.define {
+				remote.parent = main
+			}
+			target := find(main, test.target)
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+
+			remote.AliasCommand("up", target)
+			Parse(main, list{"remote", "up"})
+		})
+	}
+}
+
+// TestRunUnknownCommand tests that Run prints the usage of the deepest matched
+// command, when a sub command is unknown.
+func TestRunUnknownCommand(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want string // expected output
+	}{
+		{
+			list{"app", "a"},
+			"app a: unknown command\nRun 'app -help' for usage.\n",
+		},
+		{
+			list{"app", "remot"},
+			"app remot: unknown command\n\n" +
+				"Did you mean this?\n\tremote\n\n" +
+				"Run 'app -help' for usage.\n",
+		},
+		{
+			list{"app", "remote", "a"},
+			"app remote a: unknown command\n\n" +
+				"Did you mean this?\n\tadd\n\n" +
+				"usage: app remote \n\ncommands:\n\n\tadd \n",
+		},
+		{
+			list{"app", "remote", "remove"},
+			"app remote remove: unknown command\n\n" +
+				"usage: app remote \n\ncommands:\n\n\tadd \n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := build(list{"test", "remote", "add"})
+
+			status := 0
+			output := capture(t, func() {
+				status = run(main, test.argv)
+			})
+			if status != ExitUsageError {
+				t.Errorf("got status %d, want %d", status, ExitUsageError)
+			}
+			if output != test.want {
+				t.Errorf("got output %q, want %q", output, test.want)
+			}
+		})
+	}
+}
+
+// TestRunParseErrorCode tests that Run returns the exit status configured for
+// each category of parsing error.
+func TestRunParseErrorCode(t *testing.T) {
+	defer func() {
+		FlagErrorCode = ExitUsageError
+		UnknownCommandCode = ExitUsageError
+		NoCommandCode = ExitUsageError
+	}()
+	FlagErrorCode = 3
+	UnknownCommandCode = 127
+	NoCommandCode = 4
+
+	var tests = []struct {
+		argv list
+		want int
+	}{
+		{list{"app"}, 4},
+		{list{"app", "a"}, 127},
+		{list{"app", "remote", "a"}, 127},
+		{list{"app", "-x", "remote"}, 3},
+		{list{"app", "remote", "-x"}, 3},
+		{list{"app", "-h"}, ExitUsageError},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := build(list{"test", "remote", "add"})
+
+			status := 0
+			capture(t, func() {
+				status = run(main, test.argv)
+			})
+			if status != test.want {
+				t.Errorf("got status %d, want %d", status, test.want)
+			}
+		})
+	}
+}
+
+// TestRunVirtual tests that Run prints the usage of a virtual command, even if
+// its Run field is set.
+func TestRunVirtual(t *testing.T) {
+	var tests = []struct {
+		names list
+		want  string // expected output
+	}{
+		{
+			list{"test", "group", "a"},
+			"app group: no command\n" +
+				"usage: app group \n\ncommands:\n\n\ta \n",
+		},
+		{
+			list{"test", "group"},
+			"usage: app group \n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.names)), func(t *testing.T) {
+			main := build(test.names)
+			group := main.Commands[0]
+			group.Virtual = true
+			group.Run = func(cmd *Command, args []string) int {
+				t.Errorf("virtual command run")
+
+				return ExitSuccess
+			}
+			if group.Runnable() {
+				t.Errorf("virtual command is runnable")
+			}
+
+			status := 0
+			output := capture(t, func() {
+				status = run(main, list{"app", "group"})
+			})
+			if status != ExitUsageError {
+				t.Errorf("got status %d, want %d", status, ExitUsageError)
+			}
+			if output != test.want {
+				t.Errorf("got output %q, want %q", output, test.want)
+			}
+		})
+	}
+}
+
+// TestRunExperimental tests that Run prints a notice, only once, when an
+// experimental command is run, and that it does not run experimental commands
+// when they are not allowed.
+func TestRunExperimental(t *testing.T) {
+	defer AllowExperimental(true)
+
+	main := build(list{"test", "group", "cmd"})
+	group := main.Commands[0]
+	group.Experimental = true
+	group.Short = "group"
+	calls := 0
+	group.Commands[0].Run = func(cmd *Command, args []string) int {
+		calls++
+
+		return ExitSuccess
+	}
+
+	notice := "test group cmd: this command is experimental and may change\n"
+	for _, want := range []string{notice, ""} {
+		output := capture(t, func() {
+			run(main, list{"app", "group", "cmd"})
+		})
+		if output != want {
+			t.Errorf("got output %q, want %q", output, want)
+		}
+	}
+
+	AllowExperimental(false)
+	status := 0
+	output := capture(t, func() {
+		status = run(main, list{"app", "group", "cmd"})
+	})
+	if status != ExitUsageError {
+		t.Errorf("got status %d, want %d", status, ExitUsageError)
+	}
+	want := "test group cmd: experimental command not allowed\n"
+	if output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want %d", calls, 2)
+	}
+
+	want = "\tgroup group (experimental)\n"
+	if usage := render(t, main); !strings.HasSuffix(usage, want) {
+		t.Errorf("got usage %q, want suffix %q", usage, want)
+	}
+}
+
+// TestRunDeprecated tests that Run prints the deprecation message to
+// os.Stderr each time a deprecated command is run, unless SilenceDeprecation is
+// set, and that the command is marked as deprecated in the usage.
+func TestRunDeprecated(t *testing.T) {
+	main := build(list{"test", "old"})
+	old := main.Commands[0]
+	old.Short = "old command"
+	old.Deprecated = "use 'test new' instead"
+	calls := 0
+	old.Run = func(cmd *Command, args []string) int {
+		calls++
+
+		return ExitSuccess
+	}
+
+	want := "command \"old\" is deprecated: use 'test new' instead\n"
+	for i := 0; i < 2; i++ {
+		output := capture(t, func() {
+			run(main, list{"app", "old"})
+		})
+		if output != want {
+			t.Errorf("got output %q, want %q", output, want)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want %d", calls, 2)
+	}
+
+	want = "\told old command (deprecated)\n"
+	if usage := render(t, main); !strings.HasSuffix(usage, want) {
+		t.Errorf("got usage %q, want suffix %q", usage, want)
+	}
+
+	// The message is not printed when silenced, but the command is still
+	// marked as deprecated.
+	old.SilenceDeprecation = true
+	output := capture(t, func() {
+		run(main, list{"app", "old"})
+	})
+	if output != "" {
+		t.Errorf("unexpected output %q", output)
+	}
+	if calls != 3 {
+		t.Errorf("got %d calls, want %d", calls, 3)
+	}
+	if usage := render(t, main); !strings.HasSuffix(usage, want) {
+		t.Errorf("got usage %q, want suffix %q", usage, want)
+	}
+}
+
+// TestRunVersion tests that Run prints the version when -version or -V are
+// set, even if no command is invoked.
+func TestRunVersion(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want int
+	}{
+		{list{"app", "-version"}, ExitSuccess},
+		{list{"app", "-V"}, ExitSuccess},
+		{list{"app", "-V", "cmd"}, ExitSuccess},
+		{list{"app", "cmd", "-V"}, ExitUsageError},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := build(list{"test", "cmd"})
+			main.Version = "1.0"
+			main.Commands[0].Run = func(cmd *Command, args []string) int {
+				return ExitSuccess
+			}
+
+			status := -1
+			var stderr string
+			stdout := captureStdout(t, func() {
+				stderr = capture(t, func() {
+					status = run(main, test.argv)
+				})
+			})
+			if status != test.want {
+				t.Errorf("got status %d, want %d", status, test.want)
+			}
+			if test.want != ExitSuccess {
+				return
+			}
+			if want := "app version 1.0\n"; stdout != want {
+				t.Errorf("got stdout %q, want %q", stdout, want)
+			}
+			if stderr != "" {
+				t.Errorf("unexpected stderr %q", stderr)
+			}
+		})
+	}
+}
+
+// TestParseVersion tests that the version flags are only defined when Version
+// is set, and that they do not replace the flags defined by the command.
+func TestParseVersion(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	if _, err := Parse(main, list{"-version"}); err == nil {
+		t.Errorf("got error %v, want not nil", err)
+	}
+
+	main.Version = "1.0"
+	verbose := main.Flag.Bool("V", false, "verbose")
+	if _, err := Parse(main, list{"-version"}); err != ErrVersion {
+		t.Errorf("got error %v, want %v", err, ErrVersion)
+	}
+	if _, err := Parse(main, list{"-V", "cmd"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !*verbose {
+		t.Errorf("-V flag not set")
+	}
+}
+
+// TestRunE tests that Run calls the RunE function of a command, printing the
+// returned error and using its exit status.
+func TestRunE(t *testing.T) {
+	var tests = []struct {
+		err    error
+		status int    // expected exit status
+		want   string // expected output
+	}{
+		{nil, ExitSuccess, ""},
+		{errors.New("error"), ExitFailure, "test cmd: error\n"},
+		{exitError(3), 3, "test cmd: exit status 3\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(fmt.Sprint(test.err)), func(t *testing.T) {
+			main := build(list{"test", "cmd"})
+			cmd := main.Commands[0]
+			cmd.RunE = func(cmd *Command, args []string) error {
+				return test.err
+			}
+			if !cmd.Runnable() {
+				t.Errorf("command not runnable")
+			}
+
+			status := 0
+			output := capture(t, func() {
+				status = run(main, list{"test", "cmd"})
+			})
+			if status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+			if output != test.want {
+				t.Errorf("got output %q, want %q", output, test.want)
+			}
+		})
+	}
+}
+
+// TestRunHooks tests that Run calls PreRun and PostRun around the command,
+// and that PostRun is called even if the command failed.
+func TestRunHooks(t *testing.T) {
+	var tests = []struct {
+		name    string
+		pre     error
+		status  int
+		post    error
+		want    int
+		calls   string
+		message string
+	}{
+		{"success", nil, ExitSuccess, nil, ExitSuccess,
+			"pre run post", ""},
+		{"failure", nil, 3, nil, 3, "pre run post", ""},
+		{"pre", errors.New("pre failed"), ExitSuccess, nil, ExitFailure,
+			"pre", "test cmd: pre failed\n"},
+		{"post", nil, ExitSuccess, exitError(4), 4,
+			"pre run post", "test cmd: exit status 4\n"},
+		{"failure post", nil, 3, errors.New("post failed"), 3,
+			"pre run post", "test cmd: post failed\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.name), func(t *testing.T) {
+			var calls list
+			main := build(list{"test", "cmd"})
+			cmd := main.Commands[0]
+			cmd.PreRun = func(cmd *Command, args []string) error {
+				calls = append(calls, "pre")
+
+				return test.pre
+			}
+			cmd.Run = func(cmd *Command, args []string) int {
+				calls = append(calls, "run")
+
+				return test.status
+			}
+			cmd.PostRun = func(cmd *Command, args []string) error {
+				calls = append(calls, "post")
+
+				return test.post
+			}
+
+			status := 0
+			output := capture(t, func() {
+				status = run(main, list{"app", "cmd"})
+			})
+			if status != test.want {
+				t.Errorf("got status %d, want %d", status, test.want)
+			}
+			if got := join(calls); got != test.calls {
+				t.Errorf("got calls %q, want %q", got, test.calls)
+			}
+			if output != test.message {
+				t.Errorf("got output %q, want %q", output, test.message)
+			}
 		})
 	}
 }
 
+// TestRunPersistentHooks tests that Run calls the nearest persistent hooks of
+// the command being run, and their order with the other hooks.
+func TestRunPersistentHooks(t *testing.T) {
+	var calls list
+	hook := func(name string) func(*Command, []string) error {
+		return func(cmd *Command, args []string) error {
+			calls = append(calls, name+":"+cmd.Name)
+
+			return nil
+		}
+	}
+
+	main := build(list{"test", "group", "cmd"})
+	main.PersistentPreRun = hook("main-pre")
+	main.PersistentPostRun = hook("main-post")
+	group := main.Commands[0]
+	group.PersistentPreRun = hook("group-pre")
+	cmd := group.Commands[0]
+	cmd.PreRun = hook("pre")
+	cmd.PostRun = hook("post")
+	cmd.Run = func(cmd *Command, args []string) int {
+		calls = append(calls, "run")
+
+		return ExitSuccess
+	}
+
+	run(main, list{"app", "group", "cmd"})
+	want := "group-pre:cmd pre:cmd run post:cmd main-post:cmd"
+	if got := join(calls); got != want {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+
+	// Override the persistent hook of the parent.
+	calls = nil
+	cmd.PersistentPreRun = hook("cmd-pre")
+	run(main, list{"app", "group", "cmd"})
+	want = "cmd-pre:cmd pre:cmd run post:cmd main-post:cmd"
+	if got := join(calls); got != want {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+}
+
+// TestRunErrorPrefix tests that Run prints errors with the prefix set by
+// cmdstate.SetErrorPrefix, instead of the command name.
+func TestRunErrorPrefix(t *testing.T) {
+	defer cmdstate.SetErrorPrefix("")
+	cmdstate.SetErrorPrefix("prefix: ")
+
+	main := build(list{"test", "cmd"})
+	main.Commands[0].RunE = func(cmd *Command, args []string) error {
+		return errors.New("error")
+	}
+	output := capture(t, func() {
+		run(main, list{"test", "cmd"})
+	})
+	if want := "prefix: error\n"; output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+}
+
+// TestDispatchErrorPrefix tests that Run prints the unknown command, not
+// runnable, experimental command and conflicting run functions errors with the
+// prefix set by cmdstate.SetErrorPrefix.
+func TestDispatchErrorPrefix(t *testing.T) {
+	defer cmdstate.SetErrorPrefix("")
+	cmdstate.SetErrorPrefix("prefix: ")
+	defer AllowExperimental(true)
+	AllowExperimental(false)
+
+	noop := func(cmd *Command, args []string) int { return 0 }
+	main := build(list{"test", "cmd"})
+	main.Commands = append(main.Commands, &Command{
+		Name:         "exp",
+		Run:          noop,
+		Experimental: true,
+	}, &Command{
+		Name: "both",
+		Run:  noop,
+		RunE: func(cmd *Command, args []string) error { return nil },
+	})
+
+	var tests = []struct {
+		args []string
+		want string
+	}{
+		{list{"test", "bad"}, "prefix: bad: unknown command\n"},
+		{list{"test", "cmd"}, "prefix: not runnable\n"},
+		{list{"test", "exp"}, "prefix: experimental command not allowed\n"},
+		{list{"test", "both"}, "prefix: only one of Run, RunE and RunContext " +
+			"can be set\n"},
+	}
+	for _, test := range tests {
+		t.Run(mkname(strings.Join(test.args, " ")), func(t *testing.T) {
+			output := capture(t, func() {
+				run(main, test.args)
+			})
+			// Only check the first line of the output, ignoring the usage
+			// hints.
+			if i := strings.Index(output, "\n"); i >= 0 {
+				output = output[:i+1]
+			}
+			if output != test.want {
+				t.Errorf("got output %q, want %q", output, test.want)
+			}
+		})
+	}
+}
+
+// TestRunWithContext tests that the context passed to RunWithContext is passed
+// to the RunContext function and returned by Command.Context.
+func TestRunWithContext(t *testing.T) {
+	defer restoreArgs()()
+	os.Args = list{"test", "cmd"}
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	main := build(list{"test", "cmd"})
+	cmd := main.Commands[0]
+	cmd.RunContext = func(ctx context.Context, cmd *Command, args []string) int {
+		if v := ctx.Value(key{}); v != "value" {
+			t.Errorf("got context value %v, want %q", v, "value")
+		}
+		if cmd.Context() != ctx {
+			t.Errorf("Command.Context is not the running context")
+		}
+
+		return 3
+	}
+
+	if status := RunWithContext(ctx, main); status != 3 {
+		t.Errorf("got status %d, want %d", status, 3)
+	}
+	if cmd.Context() != context.Background() {
+		t.Errorf("Command.Context is not restored")
+	}
+}
+
+// TestCommandInvoke tests the Command.Invoke method.
+func TestCommandInvoke(t *testing.T) {
+	main := build(list{"test", "a", "child"})
+	main.Commands = append(main.Commands, &Command{Name: "b"})
+	a, b := main.Commands[0], main.Commands[1]
+	child := a.Commands[0]
+	flag := child.Flag.Bool("flag", false, "flag")
+	n := b.Flag.Int("n", 0, "n")
+
+	var calls list
+	child.Run = func(cmd *Command, args []string) int {
+		calls = append(calls, cmd.String()+" "+join(args))
+
+		return len(args)
+	}
+	b.Run = func(cmd *Command, args []string) int {
+		calls = append(calls, cmd.String())
+		if len(args) > 0 && args[0] == "loop" {
+			status := cmd.Invoke("b", "-n=2", "loop", "again")
+			calls = append(calls, fmt.Sprintf("-n=%d %s", *n,
+				join(cmd.Flag.Args())))
+
+			return status
+		}
+
+		// Invoke a sibling command.
+		return cmd.Invoke("a", "child", "-flag", "x", "y")
+	}
+
+	status := 0
+	output := capture(t, func() {
+		status = run(main, list{"test", "b"})
+	})
+	if status != 2 {
+		t.Errorf("got status %d, want %d", status, 2)
+	}
+	want := list{"test b", "test a child x y"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
+	if !*flag {
+		t.Errorf("flag not set")
+	}
+	if output != "" {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	// Invoke a sub command.
+	calls = nil
+	if status := a.Invoke("child"); status != 0 {
+		t.Errorf("got status %d, want %d", status, 0)
+	}
+	if want := (list{"test a child "}); !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
+
+	// Detect recursive invocations, without changing the flags and
+	// arguments of the running command.
+	calls = nil
+	output = capture(t, func() {
+		status = run(main, list{"test", "b", "-n=1", "loop"})
+	})
+	if status != ExitFailure {
+		t.Errorf("got status %d, want %d", status, ExitFailure)
+	}
+	if want := "test b: recursive invocation\n"; output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+	if want := (list{"test b", "-n=1 loop"}); !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
+
+	// Unknown commands are reported.
+	output = capture(t, func() {
+		status = a.Invoke("bad")
+	})
+	if status != ExitUsageError {
+		t.Errorf("got status %d, want %d", status, ExitUsageError)
+	}
+	if want := "test a bad: unknown command\n"; output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+}
+
 // TestParseFlag tests the Parse function, with a flag and an argument.
 func TestParseFlag(t *testing.T) {
 	// Define variables to keep the test entries short.
@@ -160,6 +1166,317 @@ func TestParseCustomFlags(t *testing.T) {
 	}
 }
 
+// TestParseDefault tests that Parse invokes the default sub command when no
+// sub command is specified.
+func TestParseDefault(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want string // expected command
+	}{
+		{list{}, "test status"},
+		{list{"-v"}, "test status"},
+		{list{"log"}, "test log"},
+		{list{"remote"}, "test remote list"},
+		{list{"remote", "add"}, "test remote add"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := &Command{
+				Name:    "test",
+				Default: "status",
+				Commands: []*Command{
+					{Name: "status"},
+					{Name: "log"},
+					{
+						Name:     "remote",
+						Default:  "list",
+						Commands: []*Command{{Name: "list"}, {Name: "add"}},
+					},
+				},
+			}
+			main.Flag.Bool("v", false, "verbose")
+
+			cmd, err := Parse(main, test.argv)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := cmd.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestParseDefaultUndefined tests that Parse reports a default sub command
+// that is not defined.
+func TestParseDefaultUndefined(t *testing.T) {
+	main := build(list{"test", "remote", "add"})
+	main.Commands[0].Default = "list"
+
+	_, err := Parse(main, list{"remote"})
+	want := `default command "list" not defined`
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+// TestParseCaseInsensitive tests that Parse matches the sub commands ignoring
+// case, preferring an exact match and then the first declared command.
+func TestParseCaseInsensitive(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want string // expected command
+	}{
+		{list{"build"}, "test build"},
+		{list{"Build"}, "test Build"},
+		{list{"BUILD"}, "test build"},
+		{list{"Remote", "ADD"}, "test remote add"},
+		{list{"RM"}, "test remote remove"},
+		{list{"Mk"}, "test Build"}, // "MK" sorts before "mk"
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := &Command{
+				Name:            "test",
+				CaseInsensitive: true,
+				Commands: []*Command{
+					{Name: "build"},
+					{Name: "Build"},
+					{
+						Name: "remote",
+						Commands: []*Command{
+							{Name: "add"}, {Name: "remove"},
+						},
+					},
+				},
+			}
+			remote := main.Commands[2]
+			main.AliasCommand("rm", remote.Commands[1])
+			main.AliasCommand("mk", main.Commands[0])
+			main.AliasCommand("MK", main.Commands[1])
+
+			cmd, err := Parse(main, test.argv)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := cmd.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	// Case is significant by default.
+	main := build(list{"test", "build"})
+	if _, err := Parse(main, list{"Build"}); err != ErrUnknownCommand {
+		t.Errorf("got error %v, want %v", err, ErrUnknownCommand)
+	}
+}
+
+// TestParsePrefixMatch tests that Parse matches the sub commands by an
+// unambiguous prefix, preferring an exact match.
+func TestParsePrefixMatch(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want string // expected command
+		err  string // expected error
+	}{
+		{list{"stat"}, "test status", ""},
+		{list{"st"}, "test st", ""}, // exact match
+		{list{"sto"}, "test stop", ""},
+		{list{"sta"}, "", `ambiguous command "sta", could be start, status`},
+		{list{"star"}, "test start", ""},
+		{list{"rem", "a"}, "test remote add", ""},
+		{list{"x"}, "", ErrUnknownCommand.Error()},
+		{list{"sec"}, "", ErrUnknownCommand.Error()},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := &Command{
+				Name:             "test",
+				AllowPrefixMatch: true,
+				Commands: []*Command{
+					{Name: "start"},
+					{Name: "status"},
+					{Name: "st"},
+					{Name: "stop"},
+					{Name: "secret", Hidden: true},
+					{
+						Name: "remote",
+						Commands: []*Command{
+							{Name: "add"}, {Name: "remove"},
+						},
+					},
+				},
+			}
+			cmd, err := Parse(main, test.argv)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := cmd.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	// Prefix matching is disabled by default.
+	main := build(list{"test", "status"})
+	if _, err := Parse(main, list{"sta"}); err != ErrUnknownCommand {
+		t.Errorf("got error %v, want %v", err, ErrUnknownCommand)
+	}
+}
+
+// TestParseTerminator tests that the arguments after the "--" terminator are
+// returned verbatim, at any level of the command tree.
+func TestParseTerminator(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want list // expected arguments
+	}{
+		{list{"cmd", "--", "-notaflag", "file"}, list{"-notaflag", "file"}},
+		{list{"-v", "cmd", "--", "-v"}, list{"-v"}},
+		{list{"cmd", "--", "a", "--", "-b"}, list{"a", "--", "-b"}},
+		{list{"group", "sub", "-v", "--", "-x"}, list{"-x"}},
+		{list{"group", "--", "sub", "-x"}, list{"sub", "-x"}},
+		{list{"--", "cmd", "-x"}, list{"cmd", "-x"}},
+		{list{"-v", "--", "cmd"}, list{"cmd"}},
+		{list{"-o", "--", "cmd", "x"}, list{"x"}},
+		{list{"custom", "-v", "--", "-x"}, list{"-v", "--", "-x"}},
+		{list{"lenient", "-x=1", "--", "-y"}, list{"-x=1", "-y"}},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := &Command{
+				Name: "test",
+				Commands: []*Command{
+					{Name: "cmd"},
+					{Name: "group", Commands: []*Command{{Name: "sub"}}},
+					{Name: "custom", CustomFlags: true},
+					{
+						Name: "lenient",
+						FParseErrWhitelist: ParseErrorsWhitelist{
+							UnknownFlags: true,
+						},
+					},
+				},
+			}
+			main.PersistentFlag.Bool("v", false, "verbose")
+			main.Flag.String("o", "", "output")
+
+			cmd, err := Parse(main, test.argv)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if args := cmd.Flag.Args(); !reflect.DeepEqual(args, test.want) {
+				t.Errorf("got arguments %q, want %q", args, test.want)
+			}
+		})
+	}
+}
+
+// TestParseUnknownFlags tests the Parse function, when the command has the
+// FParseErrWhitelist.UnknownFlags field set to true.
+func TestParseUnknownFlags(t *testing.T) {
+	var tests = []struct {
+		argv list
+		args list // expected arguments
+		err  bool // expected error
+	}{
+		{list{"test", "cmd", "-flag", "arg"}, list{"arg"}, false},
+		{list{"test", "cmd", "-x", "-flag", "arg"}, list{"-x", "arg"}, false},
+		{
+			list{"test", "cmd", "-x=1", "-flag", "-y", "arg"},
+			list{"-x=1", "-y", "arg"},
+			false,
+		},
+		{list{"test", "cmd", "-flag=bad", "arg"}, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := build(list{"test", "cmd"})
+			main.Commands[0].FParseErrWhitelist.UnknownFlags = true
+			flag := main.Commands[0].Flag.Bool("flag", false, "flag")
+
+			cmd, err := Parse(main, test.argv[1:])
+			if test.err {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !*flag {
+				t.Errorf("flag not set")
+			}
+			if args := cmd.Flag.Args(); !reflect.DeepEqual(args, test.args) {
+				t.Errorf("got arguments %q, want %q", args, test.args)
+			}
+		})
+	}
+
+	// Unknown flags are still rejected when not whitelisted.
+	main := build(list{"test", "cmd"})
+	if _, err := Parse(main, list{"cmd", "-x"}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+// TestParsePreParse tests the Parse function, when the main command has the
+// PreParse field set.
+func TestParsePreParse(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	main.PreParse = func(argv []string) ([]string, error) {
+		if len(argv) > 0 && argv[0] == "bad" {
+			return nil, errors.New("bad alias")
+		}
+		if len(argv) > 0 && argv[0] == "c" {
+			argv = append(list{"cmd", "-flag"}, argv[1:]...)
+		}
+
+		return argv, nil
+	}
+	flag := main.Commands[0].Flag.Bool("flag", false, "flag")
+
+	cmd, err := Parse(main, list{"c", "arg"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if cmd.Name != "cmd" {
+		t.Errorf("got command %q, want %q", cmd.Name, "cmd")
+	}
+	if !*flag {
+		t.Errorf("flag not set")
+	}
+	if args := cmd.Flag.Args(); !reflect.DeepEqual(args, list{"arg"}) {
+		t.Errorf("got arguments %q, want %q", args, list{"arg"})
+	}
+
+	status := 0
+	output := capture(t, func() {
+		status = run(main, list{"app", "bad"})
+	})
+	if status != ExitUsageError {
+		t.Errorf("got status %d, want %d", status, ExitUsageError)
+	}
+	if want := "app: bad alias\n"; !strings.HasPrefix(output, want) {
+		t.Errorf("got output %q, want prefix %q", output, want)
+	}
+}
+
 // TestParseMainFlagsSet tests the Parse function, when the main command has
 // flags set and additional sub commands.
 func TestParseMainFlagsSet(t *testing.T) {
@@ -192,6 +1509,128 @@ func TestParseMainFlagsSet(t *testing.T) {
 	}
 }
 
+// TestTopics tests that help topics are listed in the default usage, but are
+// not matched by Parse.
+func TestTopics(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	main.Topics = []*HelpTopic{
+		{Name: "topic", Long: "topic description\n\nMore details."},
+	}
+
+	usage := render(t, main)
+	want := "\nadditional help topics:\n\n\ttopic topic description\n"
+	if !strings.HasSuffix(usage, want) {
+		t.Errorf("got usage %q, want suffix %q", usage, want)
+	}
+
+	if _, err := Parse(main, list{"topic"}); err != ErrUnknownCommand {
+		t.Errorf("got error %v, want %v", err, ErrUnknownCommand)
+	}
+}
+
+// TestHidden tests that an hidden command is not listed in the usage output
+// of its parent, but it is still matched by Parse.
+func TestHidden(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	main.Commands = append(main.Commands, &Command{Name: "debug", Hidden: true})
+
+	want := "usage: test \n\ncommands:\n\n\tcmd \n"
+	if usage := render(t, main); usage != want {
+		t.Errorf("got usage %q, want %q", usage, want)
+	}
+
+	cmd, err := Parse(main, list{"debug"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if cmd.String() != "test debug" {
+		t.Errorf("got %q, want %q", cmd.String(), "test debug")
+	}
+
+	// Only hidden commands.
+	main.Commands[0].Hidden = true
+	if main.HasSubCommands() {
+		t.Errorf("got %t, want %t", true, false)
+	}
+	if usage := render(t, main); usage != "usage: test \n" {
+		t.Errorf("got usage %q, want %q", usage, "usage: test \n")
+	}
+}
+
+// TestUsageFooter tests that the usage footer is printed at the end of the
+// default usage, and that the command footer overrides the global one.
+func TestUsageFooter(t *testing.T) {
+	defer SetUsageFooter("")
+
+	var tests = []struct {
+		global string
+		footer string
+		want   string // expected usage suffix
+	}{
+		{"", "", "\tcmd \n"},
+		{"global", "", "\n\nglobal\n"},
+		{"", "footer", "\n\nfooter\n"},
+		{"global", "footer", "\n\nfooter\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.global+":"+test.footer), func(t *testing.T) {
+			main := build(list{"test", "cmd"})
+			main.Footer = test.footer
+			SetUsageFooter(test.global)
+
+			usage := render(t, main)
+			if !strings.HasSuffix(usage, test.want) {
+				t.Errorf("got usage %q, want suffix %q", usage, test.want)
+			}
+		})
+	}
+}
+
+// render returns the usage of c, as rendered by Command.defaultUsage.
+func render(t *testing.T, c *Command) string {
+	var b bytes.Buffer
+	if err := c.defaultUsage(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.String()
+}
+
+// capture returns the data written to os.Stderr while calling f.
+func capture(t *testing.T, f func()) string {
+	return redirect(t, &os.Stderr, f)
+}
+
+// captureStdout returns the data written to os.Stdout while calling f.
+func captureStdout(t *testing.T, f func()) string {
+	return redirect(t, &os.Stdout, f)
+}
+
+// redirect returns the data written to *std while calling f.
+func redirect(t *testing.T, std **os.File, f func()) string {
+	file, err := ioutil.TempFile("", "std")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	orig := *std
+	*std = file
+	func() {
+		defer func() { *std = orig }()
+		f()
+	}()
+
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
 // buildp returns a command tree, with the parent field set correctly.
 func buildp(tree []string) *Command {
 	var parent, cmd *Command
@@ -234,6 +1673,14 @@ func join(elems []string) string {
 	return strings.Join(elems, " ")
 }
 
+// TestMain makes the default usage output independent of the terminal the
+// tests are run from.
+func TestMain(m *testing.M) {
+	SetColorMode(ColorNever)
+	SetUsageWidth(defaultUsageWidth)
+	os.Exit(m.Run())
+}
+
 // mkname returns a suitable name to use for a sub test.
 func mkname(s string) string {
 	if s == "" {
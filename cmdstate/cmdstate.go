@@ -3,65 +3,102 @@
 // license that can be found in the LICENSE file.
 
 // Package cmdstate manages a running command state (the exit status).
+//
+// The package level functions use a default Exiter, that calls os.Exit.
 package cmdstate
 
 import (
-	"fmt"
+	"io"
 	"os"
-	"sync"
 )
 
-var atExitFuncs []func()
+// std is the default Exiter.
+var std = NewExiter(os.Exit)
 
 // AtExit will call f when Exit is called.
 func AtExit(f func()) {
-	atExitFuncs = append(atExitFuncs, f)
+	std.AtExit(f)
 }
 
 // Exit calls os.Exit with the exit status as set by SetExitStatus.  It calls
-// all the function registered by AtExit in FIFO order.
+// all the function registered by AtExit in LIFO order, like deferred
+// functions.
 func Exit() {
-	for _, f := range atExitFuncs {
-		f()
-	}
+	std.Exit()
+}
 
-	os.Exit(exitStatus)
+// RunAtExit calls all the function registered by AtExit in LIFO order, without
+// exiting.  The functions are called only once, even if Exit is called later.
+func RunAtExit() {
+	std.RunAtExit()
 }
 
-// Fatalf prints the formatted message on os.Stderr and exit with exit status
-// 1.
+// Fatalf prints the formatted message on os.Stderr, prefixed by the error
+// prefix, and exit with exit status 1.
 func Fatalf(format string, args ...interface{}) {
-	Errorf(format, args...)
-	Exit()
+	std.Fatalf(format, args...)
 }
 
-// Errorf prints the formatted message on os.Stderr and set the exit status to
-// 1.
+// Errorf prints the formatted message on os.Stderr, prefixed by the error
+// prefix, and set the exit status to 1.
 func Errorf(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, format, args...)
-	SetExitStatus(1)
+	std.Errorf(format, args...)
 }
 
-// ExitIfErrors will exit if the current exit status is not 0.
-func ExitIfErrors() {
-	if exitStatus != 0 {
-		Exit()
+var errorPrefix string
+
+// SetErrorPrefix sets the prefix of the error messages printed by Errorf and
+// Fatalf, like "app: ".  The prefix is also used by the cmd package instead
+// of the command name, when printing errors.  By default the prefix is empty.
+func SetErrorPrefix(prefix string) {
+	errorPrefix = prefix
+}
+
+// ErrorPrefix returns the prefix set by SetErrorPrefix.
+func ErrorPrefix() string {
+	return errorPrefix
+}
+
+var output io.Writer
+
+// SetOutput sets the destination of the messages printed by Errorf and
+// Fatalf.  The destination is also used by the cmd package for the usage and
+// the error messages.  All the messages documented as printed on os.Stderr
+// are written to w instead.  A nil w restores the default, os.Stderr.
+func SetOutput(w io.Writer) {
+	output = w
+}
+
+// Output returns the destination set by SetOutput, or os.Stderr if not set.
+func Output() io.Writer {
+	if output == nil {
+		return os.Stderr
 	}
+
+	return output
 }
 
-var exitMu sync.Mutex // guards exitStatus
-var exitStatus = 0
+// ExitIfErrors will exit if the current exit status is not 0.
+func ExitIfErrors() {
+	std.ExitIfErrors()
+}
 
 // SetExitStatus sets the exit status to n.
 func SetExitStatus(n int) {
-	exitMu.Lock()
-	if exitStatus < n {
-		exitStatus = n
-	}
-	exitMu.Unlock()
+	std.SetExitStatus(n)
 }
 
 // GetExitStatus returns the current exit status.
 func GetExitStatus() int {
-	return exitStatus
+	return std.GetExitStatus()
+}
+
+// Warn increments the warning count.  It does not change the exit status.
+func Warn() {
+	std.Warn()
+}
+
+// WarningCount returns the current warning count.
+func WarningCount() int {
+	return std.WarningCount()
 }
@@ -4,24 +4,99 @@
 
 package cmdstate
 
-import "testing"
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
 
 // TestErrorf tests that a call to Errorf sets the exit status to 1.
 func TestErrorf(t *testing.T) {
+	defer isolate()()
 	Errorf("error")
 	if code := GetExitStatus(); code != 1 {
 		t.Errorf("git %d, want %d", code, 1)
 	}
 }
 
+// TestSetErrorPrefix tests that Errorf prints the prefix set by
+// SetErrorPrefix.
+func TestSetErrorPrefix(t *testing.T) {
+	defer SetErrorPrefix("")
+	SetErrorPrefix("app: ")
+
+	file, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	stderr := os.Stderr
+	os.Stderr = file
+	Errorf("error %d\n", 1)
+	os.Stderr = stderr
+
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "app: error 1\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestSetOutput tests that Errorf prints to the destination set by SetOutput.
+func TestSetOutput(t *testing.T) {
+	defer SetOutput(nil)
+	var buf bytes.Buffer
+	SetOutput(&buf)
+
+	Errorf("error %d\n", 1)
+	if got, want := buf.String(), "error 1\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	SetOutput(nil)
+	if Output() != os.Stderr {
+		t.Errorf("default output not restored")
+	}
+}
+
 // TestSetExitStatus tests that a call to SetExitStatus(n) sets the exit status
 // to n.
 func TestSetExitStatus(t *testing.T) {
+	defer isolate()()
 	SetExitStatus(2)
 	if code := GetExitStatus(); code != 2 {
 		t.Errorf("git %d, want %d", code, 2)
 	}
 }
 
-// Exit (and AtExit), ExitIfErrors and Fatalf can not be tested since they call
-// os.Exit.
+// TestWarn tests that a call to Warn increments the warning count without
+// changing the exit status.
+func TestWarn(t *testing.T) {
+	status := GetExitStatus()
+	count := WarningCount()
+	Warn()
+	Warn()
+	if n := WarningCount(); n != count+2 {
+		t.Errorf("got %d warnings, want %d", n, count+2)
+	}
+	if code := GetExitStatus(); code != status {
+		t.Errorf("got %d, want %d", code, status)
+	}
+}
+
+// isolate replaces the Exiter used by the package functions with a new one,
+// so that a test does not depend on the exit status set by the other tests.
+// It returns a function restoring the previous Exiter.
+func isolate() func() {
+	prev := std
+	std = NewExiter(os.Exit)
+
+	return func() {
+		std = prev
+	}
+}
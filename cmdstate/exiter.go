@@ -0,0 +1,123 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdstate
+
+import (
+	"fmt"
+	"sync"
+)
+
+// An Exiter manages the state of a running command: the exit status, the
+// warning count and the functions to call on exit.  The package level
+// functions use a default Exiter, but a program (or a test) can create its own
+// Exiter, with a custom exit function.
+type Exiter struct {
+	exit func(code int)
+
+	mu          sync.Mutex // guards the fields below
+	atExitFuncs []func()
+	exitStatus  int
+	warnCount   int
+}
+
+// NewExiter returns a new Exiter that calls exit to terminate the program.
+func NewExiter(exit func(code int)) *Exiter {
+	return &Exiter{exit: exit}
+}
+
+// AtExit will call f when Exit is called.
+func (e *Exiter) AtExit(f func()) {
+	e.mu.Lock()
+	e.atExitFuncs = append(e.atExitFuncs, f)
+	e.mu.Unlock()
+}
+
+// Exit calls the exit function with the exit status as set by SetExitStatus.
+// It calls all the function registered by AtExit, as documented in RunAtExit.
+func (e *Exiter) Exit() {
+	e.RunAtExit()
+	e.exit(e.GetExitStatus())
+}
+
+// RunAtExit calls all the function registered by AtExit in LIFO order, like
+// deferred functions, without exiting.  A panic in a function is reported on
+// os.Stderr, and the remaining functions are still called.  The functions are
+// unregistered, so that they are called only once.
+func (e *Exiter) RunAtExit() {
+	e.mu.Lock()
+	funcs := e.atExitFuncs
+	e.atExitFuncs = nil
+	e.mu.Unlock()
+	for i := len(funcs) - 1; i >= 0; i-- {
+		call(funcs[i])
+	}
+}
+
+// call calls f, reporting a panic on os.Stderr.
+func call(f func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			fmt.Fprintf(Output(), "%spanic in AtExit function: %v\n",
+				ErrorPrefix(), v)
+		}
+	}()
+
+	f()
+}
+
+// Fatalf prints the formatted message on os.Stderr, prefixed by the error
+// prefix, and exit with exit status 1.
+func (e *Exiter) Fatalf(format string, args ...interface{}) {
+	e.Errorf(format, args...)
+	e.Exit()
+}
+
+// Errorf prints the formatted message on os.Stderr, prefixed by the error
+// prefix, and set the exit status to 1.
+func (e *Exiter) Errorf(format string, args ...interface{}) {
+	w := Output()
+	fmt.Fprint(w, ErrorPrefix())
+	fmt.Fprintf(w, format, args...)
+	e.SetExitStatus(1)
+}
+
+// ExitIfErrors will exit if the current exit status is not 0.
+func (e *Exiter) ExitIfErrors() {
+	if e.GetExitStatus() != 0 {
+		e.Exit()
+	}
+}
+
+// SetExitStatus sets the exit status to n.
+func (e *Exiter) SetExitStatus(n int) {
+	e.mu.Lock()
+	if e.exitStatus < n {
+		e.exitStatus = n
+	}
+	e.mu.Unlock()
+}
+
+// GetExitStatus returns the current exit status.
+func (e *Exiter) GetExitStatus() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.exitStatus
+}
+
+// Warn increments the warning count.  It does not change the exit status.
+func (e *Exiter) Warn() {
+	e.mu.Lock()
+	e.warnCount++
+	e.mu.Unlock()
+}
+
+// WarningCount returns the current warning count.
+func (e *Exiter) WarningCount() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.warnCount
+}
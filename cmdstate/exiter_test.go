@@ -0,0 +1,177 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdstate
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// recorder records the exit code passed to the exit function.
+type recorder struct {
+	code   int
+	called bool
+}
+
+func (r *recorder) exit(code int) {
+	r.code = code
+	r.called = true
+}
+
+// discardStderr redirects os.Stderr to the null device, until the returned
+// function is called.
+func discardStderr(t *testing.T) func() {
+	file, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = file
+
+	return func() {
+		os.Stderr = stderr
+		file.Close()
+	}
+}
+
+// TestExiterExit tests that Exit calls the functions registered by AtExit in
+// LIFO order, and then the exit function with the current exit status.
+func TestExiterExit(t *testing.T) {
+	var r recorder
+	var calls []string
+	e := NewExiter(r.exit)
+	e.AtExit(func() { calls = append(calls, "a") })
+	e.AtExit(func() { calls = append(calls, "b") })
+	e.AtExit(func() { calls = append(calls, "c") })
+	e.SetExitStatus(2)
+	e.Exit()
+
+	if !r.called {
+		t.Fatal("exit function not called")
+	}
+	if r.code != 2 {
+		t.Errorf("got %d, want %d", r.code, 2)
+	}
+	if got, want := strings.Join(calls, " "), "c b a"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestExiterExitPanic tests that Exit calls all the functions registered by
+// AtExit, even if one of them panics.
+func TestExiterExitPanic(t *testing.T) {
+	defer discardStderr(t)()
+
+	var r recorder
+	var calls []string
+	e := NewExiter(r.exit)
+	e.AtExit(func() { calls = append(calls, "a") })
+	e.AtExit(func() { panic("cleanup") })
+	e.AtExit(func() { calls = append(calls, "c") })
+	e.SetExitStatus(3)
+	e.Exit()
+
+	if !r.called {
+		t.Fatal("exit function not called")
+	}
+	if r.code != 3 {
+		t.Errorf("got %d, want %d", r.code, 3)
+	}
+	if got, want := strings.Join(calls, " "), "c a"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestExiterRunAtExit tests that RunAtExit calls the functions registered by
+// AtExit only once, without exiting.
+func TestExiterRunAtExit(t *testing.T) {
+	var r recorder
+	calls := 0
+	e := NewExiter(r.exit)
+	e.AtExit(func() { calls++ })
+	e.RunAtExit()
+	if r.called {
+		t.Fatal("exit function called")
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want %d", calls, 1)
+	}
+
+	e.Exit()
+	if !r.called {
+		t.Fatal("exit function not called")
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want %d", calls, 1)
+	}
+}
+
+// TestExiterExitIfErrors tests that ExitIfErrors exits only when the exit
+// status is not 0.
+func TestExiterExitIfErrors(t *testing.T) {
+	var r recorder
+	e := NewExiter(r.exit)
+	e.ExitIfErrors()
+	if r.called {
+		t.Fatal("exit function called with exit status 0")
+	}
+
+	e.SetExitStatus(1)
+	e.ExitIfErrors()
+	if !r.called {
+		t.Fatal("exit function not called")
+	}
+	if r.code != 1 {
+		t.Errorf("got %d, want %d", r.code, 1)
+	}
+}
+
+// TestExiterFatalf tests that Fatalf prints the message and exits with exit
+// status 1.
+func TestExiterFatalf(t *testing.T) {
+	file, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	var r recorder
+	e := NewExiter(r.exit)
+	stderr := os.Stderr
+	os.Stderr = file
+	e.Fatalf("fatal %d\n", 1)
+	os.Stderr = stderr
+
+	if !r.called {
+		t.Fatal("exit function not called")
+	}
+	if r.code != 1 {
+		t.Errorf("got %d, want %d", r.code, 1)
+	}
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "fatal 1\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestExiterSetExitStatus tests that SetExitStatus never decreases the exit
+// status.
+func TestExiterSetExitStatus(t *testing.T) {
+	defer discardStderr(t)()
+
+	e := NewExiter(nil)
+	e.SetExitStatus(3)
+	e.SetExitStatus(1)
+	e.Errorf("error\n")
+	if code := e.GetExitStatus(); code != 3 {
+		t.Errorf("got %d, want %d", code, 3)
+	}
+}
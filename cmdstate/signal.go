@@ -0,0 +1,66 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdstate
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// NotifyContext returns a context that is canceled when the program receives
+// an os.Interrupt or syscall.SIGTERM signal.  When a second signal is
+// received, the exit status is set to 128 plus the signal number and Exit is
+// called, running all the functions registered by AtExit.
+//
+// Calling the returned stop function restores the default signal handling and
+// cancels the context.  It is safe to call stop more than once.
+func NotifyContext() (ctx context.Context, stop func()) {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	return notifyContext(std, c, func() {
+		signal.Stop(c)
+	})
+}
+
+// notifyContext implements NotifyContext for e, reading the signals from c.
+// The unnotify function is called by stop to stop the delivery of the
+// signals.
+func notifyContext(e *Exiter, c <-chan os.Signal, unnotify func()) (
+	context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n := 0
+		for {
+			select {
+			case sig := <-c:
+				n++
+				if n == 1 {
+					cancel() // graceful
+					continue
+				}
+				e.SetExitStatus(signalStatus(sig))
+				e.Exit() // immediate
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			unnotify()
+			close(done)
+			cancel()
+		})
+	}
+
+	return ctx, stop
+}
@@ -0,0 +1,15 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdstate
+
+import (
+	"os"
+)
+
+// signalStatus returns the exit status of a program terminated by sig.  Plan
+// 9 notes have no number, so it always returns 1.
+func signalStatus(sig os.Signal) int {
+	return 1
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdstate
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestNotifyContext tests that the first signal cancels the context, and that
+// the second signal calls Exit.
+func TestNotifyContext(t *testing.T) {
+	exited := make(chan struct{})
+	e := NewExiter(func(int) {
+		close(exited)
+	})
+
+	c := make(chan os.Signal)
+	unnotified := false
+	ctx, stop := notifyContext(e, c, func() {
+		unnotified = true
+	})
+	defer stop()
+
+	c <- syscall.SIGTERM
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context not canceled")
+	}
+	select {
+	case <-exited:
+		t.Fatal("unexpected exit on the first signal")
+	default:
+	}
+
+	c <- syscall.SIGTERM
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("no exit on the second signal")
+	}
+	code, want := e.GetExitStatus(), signalStatus(syscall.SIGTERM)
+	if code != want {
+		t.Errorf("got exit status %d, want %d", code, want)
+	}
+
+	stop()
+	if !unnotified {
+		t.Errorf("signals still delivered after stop")
+	}
+}
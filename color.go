@@ -0,0 +1,204 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/perillo/cmd/cmdstate"
+)
+
+// A ColorMode controls the use of colors in the default usage output.
+type ColorMode int
+
+// The color modes.
+const (
+	// ColorAuto enables colors when the usage is written to a terminal and
+	// the NO_COLOR environment variable is empty, as documented in
+	// https://no-color.org.
+	ColorAuto ColorMode = iota
+
+	// ColorAlways always enables colors.
+	ColorAlways
+
+	// ColorNever always disables colors.
+	ColorNever
+)
+
+// colorMode is the mode set by SetColorMode.
+var colorMode ColorMode
+
+// SetColorMode sets the use of colors in the default usage output, where the
+// command names are shown in bold and the descriptions dimmed.  The default
+// mode is ColorAuto.
+func SetColorMode(mode ColorMode) {
+	colorMode = mode
+}
+
+// String implements the flag.Value interface.
+func (m ColorMode) String() string {
+	switch m {
+	case ColorAlways:
+		return "always"
+	case ColorNever:
+		return "never"
+	}
+
+	return "auto"
+}
+
+// Set implements the flag.Value interface.  It accepts the auto, always and
+// never values.
+func (m *ColorMode) Set(s string) error {
+	switch s {
+	case "auto":
+		*m = ColorAuto
+	case "always":
+		*m = ColorAlways
+	case "never":
+		*m = ColorNever
+	default:
+		return fmt.Errorf("invalid color mode %q, expected auto, always or "+
+			"never", s)
+	}
+
+	return nil
+}
+
+// ColorFlag returns a flag.Value accepting auto, always and never, that sets
+// the mode like SetColorMode, to be used like:
+//
+//	main.PersistentFlag.Var(cmd.ColorFlag(), "color",
+//		"use colors: auto, always or never")
+func ColorFlag() flag.Value {
+	return &colorMode
+}
+
+// EnableColorFlag defines the -color persistent flag of c, using ColorFlag,
+// and the -no-color persistent flag as a shorthand for -color=never.  Since the
+// flags are parsed before the sub commands, the mode also applies to the usage
+// printed in case of a parsing error of a sub command.
+func (c *Command) EnableColorFlag() {
+	c.PersistentFlag.Var(ColorFlag(), "color",
+		"use colors: auto, always or never")
+	c.PersistentFlag.Var(noColorFlag{}, "no-color",
+		"do not use colors, like -color=never")
+}
+
+// noColorFlag is the flag.Value of the -no-color flag.  Setting it to false
+// leaves the color mode unchanged.
+type noColorFlag struct{}
+
+// String implements the flag.Value interface.
+func (noColorFlag) String() string {
+	return "false"
+}
+
+// Set implements the flag.Value interface.
+func (noColorFlag) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if v {
+		colorMode = ColorNever
+	}
+
+	return nil
+}
+
+// IsBoolFlag implements the optional method of the flag.Value interface for
+// boolean flags.
+func (noColorFlag) IsBoolFlag() bool {
+	return true
+}
+
+// UseColor reports whether the default usage output uses colors, resolving the
+// mode set by SetColorMode or ColorFlag for the output set by
+// cmdstate.SetOutput.  In the ColorAuto mode NO_COLOR takes precedence over
+// the terminal detection.  It can be used by a command to style its own output
+// like the usage output.
+func UseColor() bool {
+	return useColor(cmdstate.Output())
+}
+
+// UsageStringPlain returns the command usage, as written by WriteUsage, without
+// colors regardless of the mode set by SetColorMode or ColorFlag.  In case of a
+// rendering error, the usage written until the error is returned.
+func (c *Command) UsageStringPlain() string {
+	defer SetColorMode(colorMode)
+	SetColorMode(ColorNever)
+
+	var b bytes.Buffer
+	c.WriteUsage(&b)
+
+	return b.String()
+}
+
+// useColor reports whether the text written to w uses colors, as documented
+// in UseColor.
+func useColor(w io.Writer) bool {
+	switch colorMode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+
+	return ok && fileWidth(f) > 0
+}
+
+// ANSI escape sequences used by the default usage output.
+const (
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+	ansiReset = "\x1b[0m"
+)
+
+// A palette styles the text of the default usage output.
+type palette struct {
+	color bool
+}
+
+// bold returns s in bold.
+func (p palette) bold(s string) string {
+	return p.style(ansiBold, s)
+}
+
+// dim returns s dimmed.
+func (p palette) dim(s string) string {
+	return p.style(ansiDim, s)
+}
+
+// style returns s with the code escape sequence, when the colors are enabled
+// and s is not empty.
+func (p palette) style(code, s string) string {
+	if !p.color || s == "" {
+		return s
+	}
+
+	return code + s + ansiReset
+}
+
+// row returns a row of the name column of the default usage output, with
+// name padded to width and followed by desc.
+func (p palette) row(name, desc string, width int) string {
+	pad := ""
+	if n := width - len(name); n > 0 {
+		pad = strings.Repeat(" ", n)
+	}
+
+	return "\t" + p.bold(name) + pad + " " + p.dim(desc) + "\n"
+}
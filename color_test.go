@@ -0,0 +1,227 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/perillo/cmd/cmdstate"
+)
+
+// colorTree returns the command tree used to test the colors.
+func colorTree() *Command {
+	return &Command{
+		Name: "app",
+		Commands: []*Command{
+			{Name: "add", Short: "add a remote"},
+			{Name: "remove"},
+		},
+		Topics: []*HelpTopic{{Name: "config", Short: "configuration"}},
+	}
+}
+
+// TestUsageColor tests that the default usage output shows the command names
+// in bold and the descriptions dimmed, only when the colors are enabled.
+func TestUsageColor(t *testing.T) {
+	defer SetColorMode(colorMode)
+
+	SetColorMode(ColorAlways)
+	want := strings.Join([]string{
+		"usage: \x1b[1mapp\x1b[0m ",
+		"",
+		"commands:",
+		"",
+		"\t\x1b[1madd\x1b[0m    \x1b[2madd a remote\x1b[0m",
+		"\t\x1b[1mremove\x1b[0m ",
+		"",
+		"additional help topics:",
+		"",
+		"\t\x1b[1mconfig\x1b[0m \x1b[2mconfiguration\x1b[0m",
+		"",
+	}, "\n")
+	if got := render(t, colorTree()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	SetColorMode(ColorNever)
+	want = strings.Join([]string{
+		"usage: app ",
+		"",
+		"commands:",
+		"",
+		"\tadd    add a remote",
+		"\tremove ",
+		"",
+		"additional help topics:",
+		"",
+		"\tconfig configuration",
+		"",
+	}, "\n")
+	if got := render(t, colorTree()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestUsageStringPlain tests that UsageStringPlain returns the usage without
+// colors, even in the ColorAlways mode, and that it does not change the mode.
+func TestUsageStringPlain(t *testing.T) {
+	defer SetColorMode(colorMode)
+
+	SetColorMode(ColorNever)
+	want := render(t, colorTree())
+	SetColorMode(ColorAlways)
+	if got := colorTree().UsageStringPlain(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if colorMode != ColorAlways {
+		t.Errorf("got %v, want %v", colorMode, ColorAlways)
+	}
+}
+
+// TestUseColor tests that UseColor resolves the color mode, with NO_COLOR
+// only overriding ColorAuto.
+func TestUseColor(t *testing.T) {
+	var tests = []struct {
+		mode    ColorMode
+		noColor string
+		want    bool
+	}{
+		{ColorAlways, "", true},
+		{ColorAlways, "1", true},
+		{ColorNever, "", false},
+		{ColorAuto, "", false}, // not a terminal
+		{ColorAuto, "1", false},
+	}
+
+	defer SetColorMode(colorMode)
+	defer cmdstate.SetOutput(nil)
+	defer os.Unsetenv("NO_COLOR")
+	cmdstate.SetOutput(new(bytes.Buffer))
+	for _, test := range tests {
+		t.Run(test.mode.String()+":"+test.noColor, func(t *testing.T) {
+			SetColorMode(test.mode)
+			os.Setenv("NO_COLOR", test.noColor)
+			if got := UseColor(); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+// TestUseColorWriter tests that in the ColorAuto mode the colors are decided
+// by the writer the usage is written to, not by the output set by
+// cmdstate.SetOutput.
+func TestUseColorWriter(t *testing.T) {
+	defer SetColorMode(colorMode)
+	SetColorMode(ColorAuto)
+	os.Unsetenv("NO_COLOR")
+
+	file, err := ioutil.TempFile("", "usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	for _, w := range []io.Writer{new(bytes.Buffer), file} {
+		if useColor(w) {
+			t.Errorf("colors enabled for %T", w)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := colorTree().WriteUsage(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "\x1b") {
+		t.Errorf("got escape sequences in %q", b.String())
+	}
+}
+
+// TestColorFlag tests that the flag returned by ColorFlag sets the color mode.
+func TestColorFlag(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want ColorMode
+		err  string // expected error message
+	}{
+		{list{"cmd"}, ColorNever, ""},
+		{list{"-color=auto", "cmd"}, ColorAuto, ""},
+		{list{"cmd", "-color", "always"}, ColorAlways, ""},
+		{list{"-color=never", "cmd"}, ColorNever, ""},
+		{list{"-color=yes", "cmd"}, ColorNever, `invalid value "yes" ` +
+			`for flag -color: invalid color mode "yes", expected auto, ` +
+			`always or never`},
+	}
+
+	defer SetColorMode(colorMode)
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			SetColorMode(ColorNever)
+			main := build(list{"test", "cmd"})
+			main.Flag.SetOutput(ioutil.Discard)
+			main.PersistentFlag.Var(ColorFlag(), "color", "use colors")
+
+			_, err := Parse(main, test.argv)
+			if msg := errorString(err); msg != test.err {
+				t.Errorf("got error %q, want %q", msg, test.err)
+			}
+			if colorMode != test.want {
+				t.Errorf("got %v, want %v", colorMode, test.want)
+			}
+		})
+	}
+}
+
+// TestEnableColorFlag tests that the flags defined by EnableColorFlag set the
+// color mode, also for the usage printed in case of a parsing error.
+func TestEnableColorFlag(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want ColorMode
+	}{
+		{list{"test", "cmd"}, ColorAuto},
+		{list{"test", "-no-color", "cmd"}, ColorNever},
+		{list{"test", "cmd", "-no-color"}, ColorNever},
+		{list{"test", "-no-color=false", "cmd"}, ColorAuto},
+		{list{"test", "-color=always", "cmd"}, ColorAlways},
+		{list{"test", "-color=always", "-no-color", "cmd"}, ColorNever},
+	}
+
+	defer SetColorMode(colorMode)
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			SetColorMode(ColorAuto)
+			main := build(list{"test", "cmd"})
+			main.EnableColorFlag()
+			main.Commands[0].Run = func(cmd *Command, args []string) int {
+				return 0
+			}
+
+			capture(t, func() {
+				run(main, test.argv)
+			})
+			if colorMode != test.want {
+				t.Errorf("got %v, want %v", colorMode, test.want)
+			}
+		})
+	}
+
+	// The usage printed for an invalid flag of a sub command uses the
+	// colors.
+	SetColorMode(ColorNever)
+	main := build(list{"test", "cmd"})
+	main.EnableColorFlag()
+	output := capture(t, func() {
+		run(main, list{"test", "-color=always", "cmd", "-bad"})
+	})
+	if want := "usage: \x1b[1mtest cmd\x1b[0m"; !strings.Contains(output, want) {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// GenBashCompletion writes to w a bash completion script for c and its sub
+// commands.  The script completes the sub command names and, for a word
+// starting with '-', the flag names of the current command, including the
+// persistent flags of its parents.  Hidden commands and flags are omitted.
+//
+// The script can be loaded with:
+//
+//	source <(app completion)
+func (c *Command) GenBashCompletion(w io.Writer) error {
+	var b bytes.Buffer
+	fname := "_" + shellIdent(c.Name)
+	fmt.Fprintf(&b, "# bash completion for %s\n\n", c.Name)
+	fmt.Fprintf(&b, "%s() {\n", fname)
+	fmt.Fprint(&b, "\tlocal cur path i\n")
+	fmt.Fprint(&b, "\tcur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&b, "\tpath=%s\n", shellQuote(c.Name))
+
+	// Find the current command, ignoring the words that are not a known
+	// sub command, like flags and their values.
+	fmt.Fprint(&b, "\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprint(&b, "\t\tcase \"$path ${COMP_WORDS[i]}\" in\n")
+	var paths []string
+	walkVisible(c, nil, func(path []*Command) {
+		if len(path) > 1 {
+			paths = append(paths, shellQuote(pathName(path)))
+		}
+	})
+	if len(paths) > 0 {
+		fmt.Fprintf(&b, "\t\t%s)\n", strings.Join(paths, " | "))
+		fmt.Fprint(&b, "\t\t\tpath=\"$path ${COMP_WORDS[i]}\"\n")
+		fmt.Fprint(&b, "\t\t\t;;\n")
+	}
+	fmt.Fprint(&b, "\t\tesac\n")
+	fmt.Fprint(&b, "\tdone\n\n")
+
+	fmt.Fprint(&b, "\tlocal words\n")
+	fmt.Fprint(&b, "\tcase \"$path\" in\n")
+	walkVisible(c, nil, func(path []*Command) {
+		cmd := path[len(path)-1]
+		var flags, names []string
+		for _, f := range visibleFlags(path) {
+			flags = append(flags, "-"+f.Name)
+		}
+		for _, sub := range visibleCommands(cmd) {
+			names = append(names, sub.Name)
+		}
+
+		fmt.Fprintf(&b, "\t%s)\n", shellQuote(pathName(path)))
+		fmt.Fprint(&b, "\t\tif [[ $cur == -* ]]; then\n")
+		fmt.Fprintf(&b, "\t\t\twords=%s\n", shellQuote(strings.Join(flags, " ")))
+		fmt.Fprint(&b, "\t\telse\n")
+		fmt.Fprintf(&b, "\t\t\twords=%s\n", shellQuote(strings.Join(names, " ")))
+		fmt.Fprint(&b, "\t\tfi\n")
+		fmt.Fprint(&b, "\t\t;;\n")
+	})
+	fmt.Fprint(&b, "\tesac\n")
+	fmt.Fprint(&b, "\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	fmt.Fprint(&b, "}\n\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", fname, c.Name)
+
+	_, err := w.Write(b.Bytes())
+
+	return err
+}
+
+// GenZshCompletion writes to w a zsh completion script for c and its sub
+// commands.  The script completes the sub command names, with their short
+// description, and the flag names of the current command, with their usage,
+// including the persistent flags of its parents.  Hidden commands and flags
+// are omitted.
+//
+// The script can be installed as a file named _app in a directory of $fpath,
+// or loaded with:
+//
+//	source <(app completion)
+func (c *Command) GenZshCompletion(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "#compdef %s\n", c.Name)
+	walkVisible(c, nil, func(path []*Command) {
+		fmt.Fprint(&b, "\n")
+		genZshFunction(&b, path)
+	})
+
+	fname := zshFunction([]*Command{c})
+	fmt.Fprint(&b, "\n")
+	fmt.Fprintf(&b, "if [ \"$funcstack[1]\" = %s ]; then\n", shellQuote(fname))
+	fmt.Fprintf(&b, "\t%s \"$@\"\n", fname)
+	fmt.Fprint(&b, "else\n")
+	fmt.Fprintf(&b, "\tcompdef %s %s\n", fname, c.Name)
+	fmt.Fprint(&b, "fi\n")
+
+	_, err := w.Write(b.Bytes())
+
+	return err
+}
+
+// genZshFunction writes to b the zsh completion function for the command at
+// the end of path.
+func genZshFunction(b *bytes.Buffer, path []*Command) {
+	cmd := path[len(path)-1]
+	subs := visibleCommands(cmd)
+	fmt.Fprintf(b, "%s() {\n", zshFunction(path))
+	if len(subs) > 0 {
+		fmt.Fprint(b, "\tlocal line state\n\n")
+		fmt.Fprint(b, "\t_arguments -C")
+	} else {
+		fmt.Fprint(b, "\t_arguments")
+	}
+	for _, f := range visibleFlags(path) {
+		fmt.Fprintf(b, " \\\n\t\t%s", shellQuote(zshFlag(f)))
+	}
+	if len(subs) == 0 {
+		fmt.Fprint(b, "\n}\n")
+
+		return
+	}
+	fmt.Fprint(b, " \\\n\t\t'1: :->cmds' \\\n\t\t'*::arg:->args'\n\n")
+
+	fmt.Fprint(b, "\tcase $state in\n")
+	fmt.Fprint(b, "\tcmds)\n")
+	fmt.Fprint(b, "\t\tlocal -a commands\n")
+	fmt.Fprint(b, "\t\tcommands=(\n")
+	for _, sub := range subs {
+		item := zshEscape(sub.Name, ":") + ":" + sub.ShortDescription()
+		fmt.Fprintf(b, "\t\t\t%s\n", shellQuote(item))
+	}
+	fmt.Fprint(b, "\t\t)\n")
+	fmt.Fprintf(b, "\t\t_describe -t commands %s commands\n",
+		shellQuote(pathName(path)+" commands"))
+	fmt.Fprint(b, "\t\t;;\n")
+	fmt.Fprint(b, "\targs)\n")
+	fmt.Fprint(b, "\t\tcase $line[1] in\n")
+	for _, sub := range subs {
+		fmt.Fprintf(b, "\t\t%s)\n", shellQuote(sub.Name))
+		fmt.Fprintf(b, "\t\t\t%s\n", zshFunction(append(path, sub)))
+		fmt.Fprint(b, "\t\t\t;;\n")
+	}
+	fmt.Fprint(b, "\t\tesac\n")
+	fmt.Fprint(b, "\t\t;;\n")
+	fmt.Fprint(b, "\tesac\n")
+	fmt.Fprint(b, "}\n")
+}
+
+// zshFunction returns the name of the zsh completion function for the command
+// at the end of path.
+func zshFunction(path []*Command) string {
+	return "_" + shellIdent(strings.Replace(pathName(path), " ", "_", -1))
+}
+
+// zshFlag returns the _arguments specification of f.  Only boolean flags do
+// not take a value.
+func zshFlag(f *flag.Flag) string {
+	name, usage := flag.UnquoteUsage(f)
+	spec := "-" + f.Name + "[" + zshEscape(firstLine(usage), "[]:") + "]"
+	if isBoolFlag(f) {
+		return spec
+	}
+
+	return spec + ":" + zshEscape(name, ":") + ":"
+}
+
+// isBoolFlag reports whether f is a boolean flag, that does not take a value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+
+	return ok && b.IsBoolFlag()
+}
+
+// zshEscape returns s with a backslash added before the backslashes and the
+// characters in chars.
+func zshEscape(s, chars string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\\' || strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// GenFishCompletion writes to w a fish completion script for c and its sub
+// commands.  The script completes the sub command names and the flag names of
+// the current command, including the persistent flags of its parents, with
+// their description.  Hidden commands and flags are omitted.
+//
+// The script can be installed as a file named app.fish in
+// ~/.config/fish/completions, or loaded with:
+//
+//	app completion | source
+func (c *Command) GenFishCompletion(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# fish completion for %s\n", c.Name)
+	walkVisible(c, nil, func(path []*Command) {
+		cmd := path[len(path)-1]
+		subs := visibleCommands(cmd)
+		prefix := "complete -c " + fishQuote(c.Name)
+		if cond := fishCondition(path, subs); cond != "" {
+			prefix += " -n " + fishQuote(cond)
+		}
+
+		fmt.Fprint(&b, "\n")
+		for _, sub := range subs {
+			fmt.Fprintf(&b, "%s -f -a %s", prefix, fishQuote(sub.Name))
+			if short := sub.ShortDescription(); short != "" {
+				fmt.Fprintf(&b, " -d %s", fishQuote(short))
+			}
+			fmt.Fprint(&b, "\n")
+		}
+		for _, f := range visibleFlags(path) {
+			_, usage := flag.UnquoteUsage(f)
+			fmt.Fprintf(&b, "%s -o %s", prefix, fishQuote(f.Name))
+			if usage = firstLine(usage); usage != "" {
+				fmt.Fprintf(&b, " -d %s", fishQuote(usage))
+			}
+			if !isBoolFlag(f) {
+				fmt.Fprint(&b, " -r")
+			}
+			fmt.Fprint(&b, "\n")
+		}
+	})
+
+	_, err := w.Write(b.Bytes())
+
+	return err
+}
+
+// fishCondition returns the condition that is true when the command at the
+// end of path is the current command, with subs being its visible sub
+// commands.
+func fishCondition(path, subs []*Command) string {
+	var conds []string
+	for _, cmd := range path[1:] {
+		conds = append(conds, "__fish_seen_subcommand_from "+cmd.Name)
+	}
+	if len(subs) > 0 {
+		names := make([]string, len(subs))
+		for i, sub := range subs {
+			names[i] = sub.Name
+		}
+		conds = append(conds, "not __fish_seen_subcommand_from "+
+			strings.Join(names, " "))
+	}
+
+	return strings.Join(conds, "; and ")
+}
+
+// fishQuote returns s quoted for fish, using single quotes.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
+
+// walkVisible calls fn with the path from the main command to c, and then
+// recursively for each visible sub command of c.  The parents are the commands
+// in the path before c.
+func walkVisible(c *Command, parents []*Command, fn func([]*Command)) {
+	path := append(parents[:len(parents):len(parents)], c)
+	fn(path)
+	for _, cmd := range visibleCommands(c) {
+		walkVisible(cmd, path, fn)
+	}
+}
+
+// visibleCommands returns the sub commands of c that are not hidden.
+func visibleCommands(c *Command) []*Command {
+	var list []*Command
+	for _, cmd := range c.Commands {
+		if !cmd.Hidden {
+			list = append(list, cmd)
+		}
+	}
+
+	return list
+}
+
+// commandPath returns the path from the main command to c.
+func commandPath(c *Command) []*Command {
+	path := []*Command{c}
+	c.VisitParents(func(p *Command) {
+		path = append([]*Command{p}, path...)
+	})
+
+	return path
+}
+
+// pathName returns the full name of the command at the end of path.
+func pathName(path []*Command) string {
+	names := make([]string, len(path))
+	for i, cmd := range path {
+		names[i] = cmd.Name
+	}
+
+	return strings.Join(names, " ")
+}
+
+// visibleFlags returns the visible flags of the command at the end of path,
+// including the persistent flags of the command and of its parents, sorted by
+// name.
+func visibleFlags(path []*Command) []*flag.Flag {
+	cmd := path[len(path)-1]
+	seen := make(map[string]bool)
+	var list []*flag.Flag
+	add := func(c *Command, f *flag.Flag) {
+		if seen[f.Name] {
+			return
+		}
+		seen[f.Name] = true
+		if !c.hiddenFlags[f.Name] {
+			list = append(list, f)
+		}
+	}
+
+	cmd.Flag.VisitAll(func(f *flag.Flag) { add(cmd, f) })
+	for i := len(path) - 1; i >= 0; i-- {
+		c := path[i]
+		c.PersistentFlag.VisitAll(func(f *flag.Flag) { add(c, f) })
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// shellQuote returns s quoted for the shell, using single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// shellIdent returns s with all the characters not valid in a shell
+// identifier replaced by '_'.
+func shellIdent(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			return r
+		case r >= '0' && r <= '9', r == '_':
+			return r
+		}
+
+		return '_'
+	}, s)
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// golden compares got with the content of the named golden file in testdata,
+// updating the file when the -update flag is set.
+func golden(t *testing.T, name string, got []byte) {
+	path := "testdata/" + name
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// completionTree returns the command tree used to test the completion
+// scripts.
+func completionTree() *Command {
+	main := &Command{
+		Name:  "app",
+		Short: "app manages remotes",
+		Commands: []*Command{
+			{
+				Name:  "remote",
+				Short: "manage remotes",
+				Commands: []*Command{
+					{Name: "add", Short: "add a remote"},
+					{Name: "remove", Short: "remove a remote"},
+				},
+			},
+			{Name: "debug", Hidden: true},
+		},
+	}
+	main.PersistentFlag.Bool("v", false, "verbose output")
+	main.Flag.String("config", "", "the config `file` [ini]")
+	main.Flag.Bool("trace", false, "trace execution")
+	main.HideFlag("trace")
+	remote := main.Commands[0]
+	remote.Flag.Bool("all", false, "all remotes")
+	remote.Commands[0].Flag.String("url", "", "remote url")
+
+	return main
+}
+
+// TestGenBashCompletion tests that the bash completion script completes the
+// sub commands and the flags of the current command.
+func TestGenBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	var script bytes.Buffer
+	if err := completionTree().GenBashCompletion(&script); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		words list // the last word is the current word
+		want  string
+	}{
+		{list{"app", ""}, "remote"},
+		{list{"app", "-"}, "-config -v"},
+		{list{"app", "-v", "r"}, "remote"},
+		{list{"app", "remote", ""}, "add remove"},
+		{list{"app", "remote", "-"}, "-all -v"},
+		{list{"app", "-config", "x", "remote", "add", "-"}, "-url -v"},
+		{list{"app", "remote", "add", ""}, ""},
+		{list{"app", "debug", "-"}, "-config -v"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.words)), func(t *testing.T) {
+			var words []string
+			for _, word := range test.words {
+				words = append(words, shellQuote(word))
+			}
+			src := script.String() +
+				"COMP_WORDS=(" + join(words) + ")\n" +
+				"COMP_CWORD=" + strconv.Itoa(len(words)-1) + "\n" +
+				"_app\n" +
+				"echo \"${COMPREPLY[*]}\"\n"
+
+			out, err := exec.Command(bash, "-c", src).CombinedOutput()
+			if err != nil {
+				t.Fatalf("bash: %v: %s", err, out)
+			}
+			if got := strings.TrimSpace(string(out)); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestGenZshCompletion tests the zsh completion script with a golden file.
+func TestGenZshCompletion(t *testing.T) {
+	var script bytes.Buffer
+	if err := completionTree().GenZshCompletion(&script); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "completion.zsh", script.Bytes())
+}
+
+// TestGenFishCompletion tests the fish completion script with a golden file.
+func TestGenFishCompletion(t *testing.T) {
+	var script bytes.Buffer
+	if err := completionTree().GenFishCompletion(&script); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "completion.fish", script.Bytes())
+}
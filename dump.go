@@ -0,0 +1,104 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/perillo/cmd/cmdstate"
+)
+
+var currentMu sync.Mutex // guards current
+var current *Command     // the running command
+
+// setCurrent sets the running command to c, returning the previous one.
+func setCurrent(c *Command) *Command {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+
+	prev := current
+	current = c
+
+	return prev
+}
+
+// redacted replaces the value of a secret flag in the configuration dumps.
+const redacted = "<redacted>"
+
+// MarkFlagSecret marks the named flag as secret, so that its value is replaced
+// by "<redacted>" in the configuration written by DumpConfigOnSignal.  The
+// flag can be defined in Flag or PersistentFlag.  MarkFlagSecret panics if the
+// flag is not defined.
+func (c *Command) MarkFlagSecret(name string) {
+	if c.lookupFlag(name) == nil {
+		panic(fmt.Sprintf("cmd: secret flag -%s not defined", name))
+	}
+	if c.secretFlags == nil {
+		c.secretFlags = make(map[string]bool)
+	}
+	c.secretFlags[name] = true
+}
+
+// A flagDump is the configuration of a command, as written by
+// DumpConfigOnSignal.
+type flagDump struct {
+	Command string            `json:"command"`
+	Flags   map[string]string `json:"flags"`
+}
+
+// DumpConfigOnSignal installs a handler for sig that, when sig is received,
+// writes to os.Stderr the name of the running command and the current value of
+// its flags, including the flags of its parents, as a JSON object.  The value
+// of the flags marked by MarkFlagSecret is redacted.  The program is not
+// terminated.
+//
+// Note that the flag values are read concurrently with the running command.
+func DumpConfigOnSignal(sig os.Signal) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sig)
+	go func() {
+		for range c {
+			currentMu.Lock()
+			cmd := current
+			currentMu.Unlock()
+			if cmd != nil {
+				dumpConfig(cmdstate.Output(), cmd)
+			}
+		}
+	}()
+}
+
+// dumpConfig writes to w the configuration of cmd, as documented in
+// DumpConfigOnSignal.  The flags of cmd take precedence over the flags of its
+// parents with the same name.
+func dumpConfig(w io.Writer, cmd *Command) error {
+	dump := flagDump{
+		Command: cmd.String(),
+		Flags:   make(map[string]string),
+	}
+	for c := cmd; c != nil; c = c.parent {
+		c.Flag.VisitAll(func(f *flag.Flag) {
+			if _, ok := dump.Flags[f.Name]; ok {
+				return
+			}
+			value := f.Value.String()
+			if c.secretFlags[f.Name] {
+				value = redacted
+			}
+			dump.Flags[f.Name] = value
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return enc.Encode(dump)
+}
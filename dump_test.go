@@ -0,0 +1,77 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestDumpConfig tests that the running command configuration is written as
+// JSON, including the parents flags.
+func TestDumpConfig(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	main.Flag.Bool("v", false, "verbose")
+	main.Flag.String("name", "main", "name")
+	main.Commands[0].Flag.String("name", "cmd", "name")
+
+	var buf bytes.Buffer
+	main.Commands[0].Run = func(cmd *Command, args []string) int {
+		if err := dumpConfig(&buf, current); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+
+		return ExitSuccess
+	}
+	run(main, list{"test", "-v", "cmd", "-name", "value"})
+	if current != nil {
+		t.Errorf("current command not restored")
+	}
+
+	want := `{"command":"test cmd","flags":{"name":"value","v":"true"}}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestMarkFlagSecret tests that the value of a secret flag is redacted, but
+// only for the command that marked it.
+func TestMarkFlagSecret(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	main.Flag.String("token", "", "main token")
+	main.MarkFlagSecret("token")
+	cmd := main.Commands[0]
+	cmd.Flag.String("user", "", "user name")
+	cmd.Flag.String("password", "", "password")
+	cmd.MarkFlagSecret("password")
+
+	args := list{"-token", "t", "cmd", "-user", "u", "-password", "p"}
+	if _, err := Parse(main, args); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := dumpConfig(&buf, cmd); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := `{"command":"test cmd","flags":{"password":"<redacted>",` +
+		`"token":"<redacted>","user":"u"}}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestMarkFlagSecretUndefined tests that MarkFlagSecret panics if the flag is
+// not defined.
+func TestMarkFlagSecretUndefined(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+
+	main := build(list{"test"})
+	main.MarkFlagSecret("undefined")
+}
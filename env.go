@@ -0,0 +1,154 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BindEnv binds the named flag to the env environment variable.  After the
+// flags are parsed, if the flag was not set on the command-line and the
+// variable is not empty, the variable value is set as the flag value, so that
+// the command-line takes precedence over the environment, and the environment
+// over the default value.  The environment is the one passed to DispatchEnv,
+// if used.  A flag set from the environment is reported as set by
+// flag.FlagSet.Visit, and it satisfies MarkFlagRequired, but it is not
+// considered set by the MarkFlagsMutuallyExclusive and
+// MarkFlagsRequiredTogether groups, that only check the command-line.  The
+// flag can be defined in Flag or PersistentFlag.  BindEnv panics if the flag is
+// not defined.
+func (c *Command) BindEnv(name, env string) {
+	if c.lookupFlag(name) == nil {
+		panic(fmt.Sprintf("cmd: flag -%s not defined", name))
+	}
+	if c.envFlags == nil {
+		c.envFlags = make(map[string]string)
+	}
+	c.envFlags[name] = env
+}
+
+// AutoEnv binds each flag of c, and of all its sub commands, to an environment
+// variable named after prefix and the flag name, in upper case and with the
+// dashes replaced by underscores, like MYTOOL_LOG_LEVEL for -log-level, as if
+// BindEnv was called for each flag.  The bindings set by BindEnv take
+// precedence, and a sub command can call AutoEnv with another prefix.  Parse
+// returns an error if more flags of a command derive the same variable name.
+func (c *Command) AutoEnv(prefix string) {
+	c.autoEnv = true
+	c.envPrefix = prefix
+}
+
+// envName returns the name of the environment variable bound to the named
+// flag by AutoEnv.
+func envName(prefix, name string) string {
+	name = strings.Replace(name, "-", "_", -1)
+
+	return strings.ToUpper(prefix + "_" + name)
+}
+
+// envBindings returns the environment variable names bound to the flags of c,
+// by BindEnv and by the AutoEnv of c or of its nearest parent.
+func (c *Command) envBindings() (map[string]string, error) {
+	auto := c
+	for auto != nil && !auto.autoEnv {
+		auto = auto.parent
+	}
+	if auto == nil {
+		return c.envFlags, nil
+	}
+
+	bindings := make(map[string]string)
+	explicit := make(map[string]bool)
+	for name, env := range c.envFlags {
+		bindings[name] = env
+		explicit[env] = true
+	}
+
+	owners := make(map[string]string) // variable to flag name
+	var err error
+	c.Flag.VisitAll(func(f *flag.Flag) {
+		if _, ok := bindings[f.Name]; ok {
+			return
+		}
+		env := envName(auto.envPrefix, f.Name)
+		if explicit[env] {
+			return // the explicit binding wins
+		}
+		if owner, ok := owners[env]; ok && err == nil {
+			err = fmt.Errorf("flags -%s and -%s are both bound to $%s",
+				owner, f.Name, env)
+		}
+		owners[env] = f.Name
+		bindings[f.Name] = env
+	})
+
+	return bindings, err
+}
+
+// applyEnv sets the flags bound to the environment, that were not set on the
+// command-line, from the environment.
+func (c *Command) applyEnv() error {
+	c.envSet = nil
+	bindings, err := c.envBindings()
+	if err != nil || len(bindings) == 0 {
+		return err
+	}
+
+	set := c.setFlags(true)
+	c.Flag.VisitAll(func(f *flag.Flag) {
+		env, ok := bindings[f.Name]
+		if !ok || set[f.Name] || err != nil {
+			return
+		}
+		value := c.getEnv(env)
+		if value == "" {
+			return
+		}
+		if e := c.Flag.Set(f.Name, value); e != nil {
+			err = fmt.Errorf("invalid value %q for flag -%s from $%s: %v",
+				value, f.Name, env, e)
+
+			return
+		}
+		if c.envSet == nil {
+			c.envSet = make(map[string]bool)
+		}
+		c.envSet[f.Name] = true
+	})
+
+	return err
+}
+
+// getEnv returns the value of the environment variable key, as read by the
+// current invocation of the command tree of c.
+func (c *Command) getEnv(key string) string {
+	if getenv := c.root().getenv; getenv != nil {
+		return getenv(key)
+	}
+
+	return os.Getenv(key)
+}
+
+// DispatchEnv is like Run, but parses the command-line from argv[1:], using
+// argv[0] as the main command name in messages, and the flags bound by BindEnv
+// and AutoEnv are set from env instead of from the process environment.  Each
+// entry of env has the form "key=value", like the result of os.Environ, and
+// the last value of a key takes precedence.  The process environment is not
+// changed.  DispatchEnv must be called on the main command.
+func (c *Command) DispatchEnv(env, argv []string) int {
+	vars := make(map[string]string)
+	for _, kv := range env {
+		if i := strings.Index(kv, "="); i >= 0 {
+			vars[kv[:i]] = kv[i+1:]
+		}
+	}
+	defer func(getenv func(string) string) { c.getenv = getenv }(c.getenv)
+	c.getenv = func(key string) string { return vars[key] }
+
+	return run(c, argv)
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+// TestBindEnv tests that a flag bound to an environment variable is set from
+// the environment, unless it is set on the command-line.
+func TestBindEnv(t *testing.T) {
+	var tests = []struct {
+		env  string
+		argv list
+		want string // expected flag value
+	}{
+		{"", list{"cmd"}, "default"},
+		{"env.ini", list{"cmd"}, "env.ini"},
+		{"env.ini", list{"cmd", "-config", "flag.ini"}, "flag.ini"},
+		{"env.ini", list{"-config", "flag.ini", "cmd"}, "flag.ini"},
+	}
+
+	const key = "CMD_TEST_CONFIG"
+	defer os.Unsetenv(key)
+	for _, test := range tests {
+		t.Run(mkname(test.env+":"+join(test.argv)), func(t *testing.T) {
+			os.Setenv(key, test.env)
+			main := build(list{"test", "cmd"})
+			config := main.PersistentFlag.String("config", "default", "")
+			main.BindEnv("config", key)
+
+			if _, err := Parse(main, test.argv); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if *config != test.want {
+				t.Errorf("got %q, want %q", *config, test.want)
+			}
+		})
+	}
+}
+
+// TestBindEnvInvalid tests that Parse reports an invalid value in the
+// environment.
+func TestBindEnvInvalid(t *testing.T) {
+	const key = "CMD_TEST_JOBS"
+	os.Setenv(key, "many")
+	defer os.Unsetenv(key)
+
+	main := build(list{"test", "cmd"})
+	main.Flag.Int("n", 1, "")
+	main.BindEnv("n", key)
+
+	want := `invalid value "many" for flag -n from $CMD_TEST_JOBS: ` +
+		`parse error`
+	if _, err := Parse(main, list{"cmd"}); errorString(err) != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+	if _, err := Parse(main, list{"-n", "2", "cmd"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// TestAutoEnv tests that AutoEnv binds the flags to the environment variables
+// derived from the prefix, giving precedence to BindEnv.
+func TestAutoEnv(t *testing.T) {
+	env := map[string]string{
+		"MYTOOL_LOG_LEVEL": "debug",
+		"MYTOOL_CONFIG":    "auto.ini",
+		"MYTOOL_N":         "2",
+		"CONFIG_FILE":      "explicit.ini",
+	}
+	for key, value := range env {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	main := build(list{"test", "cmd"})
+	main.AutoEnv("mytool")
+	level := main.PersistentFlag.String("log-level", "info", "")
+	cmd := main.Commands[0]
+	config := cmd.Flag.String("config", "", "")
+	n := cmd.Flag.Int("n", 1, "")
+	cmd.BindEnv("config", "CONFIG_FILE")
+
+	if _, err := Parse(main, list{"cmd", "-n", "3"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, want := *level, "debug"; got != want {
+		t.Errorf("got -log-level %q, want %q", got, want)
+	}
+	if got, want := *config, "explicit.ini"; got != want {
+		t.Errorf("got -config %q, want %q", got, want)
+	}
+	if got, want := *n, 3; got != want {
+		t.Errorf("got -n %d, want %d", got, want)
+	}
+}
+
+// TestAutoEnvCollision tests that Parse reports the flags deriving the same
+// environment variable name.
+func TestAutoEnvCollision(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	main.AutoEnv("MYTOOL")
+	main.Flag.String("log-level", "", "")
+	main.Flag.String("log_level", "", "")
+
+	want := "flags -log-level and -log_level are both bound to " +
+		"$MYTOOL_LOG_LEVEL"
+	if _, err := Parse(main, list{"cmd"}); errorString(err) != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+
+	// An explicit binding resolves the collision.
+	main.BindEnv("log_level", "LOG_LEVEL")
+	if _, err := Parse(main, list{"cmd"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// TestAutoEnvInheritedFlag tests that a persistent flag of the main command,
+// set on the command-line of an intermediate command, is not overwritten from
+// the environment by a sub command.
+func TestAutoEnvInheritedFlag(t *testing.T) {
+	const key = "APP_V"
+	os.Setenv(key, "fromenv")
+	defer os.Unsetenv(key)
+
+	main := build(list{"app", "sub1", "sub2"})
+	v := main.PersistentFlag.String("v", "default", "")
+	main.Commands[0].AutoEnv("APP")
+
+	if _, err := Parse(main, list{"sub1", "-v=cli", "sub2"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, want := *v, "cli"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestDispatchEnv tests that DispatchEnv sets the flags bound to the
+// environment from the specified environment, without changing the process
+// environment.
+func TestDispatchEnv(t *testing.T) {
+	const key = "CMD_TEST_CONFIG"
+	os.Setenv(key, "process.ini")
+	defer os.Unsetenv(key)
+
+	var got string
+	newMain := func() *Command {
+		main := build(list{"test", "cmd"})
+		config := main.PersistentFlag.String("config", "default", "")
+		main.BindEnv("config", key)
+		main.Commands[0].Run = func(cmd *Command, args []string) int {
+			got = *config
+
+			return ExitSuccess
+		}
+
+		return main
+	}
+
+	var tests = []struct {
+		env  list
+		want string // expected flag value
+	}{
+		{list{"OTHER=x", key + "=old.ini", key + "=env.ini"}, "env.ini"},
+		{list{key + "="}, "default"},
+		{nil, "default"}, // the process environment is not used
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.env)), func(t *testing.T) {
+			main := newMain()
+			status := main.DispatchEnv(test.env, list{"test", "cmd"})
+			if status != ExitSuccess {
+				t.Errorf("got status %d, want %d", status, ExitSuccess)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if value := os.Getenv(key); value != "process.ini" {
+				t.Errorf("process environment changed to %q", value)
+			}
+
+			if main.getenv != nil {
+				t.Errorf("environment lookup not restored")
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 
 	"github.com/perillo/cmd"
+	"github.com/perillo/cmd/cmdstate"
 )
 
 var (
@@ -50,6 +51,44 @@ func Example() {
 	// exit status: 0
 }
 
+func ExampleOnlyValidArgs() {
+	defer restore()()
+
+	// Print the error messages to os.Stdout.
+	defer cmdstate.SetOutput(nil)
+	cmdstate.SetOutput(os.Stdout)
+
+	completion := &cmd.Command{
+		Name:      "completion",
+		UsageLine: "<shell>",
+		MinArgs:   1,
+		MaxArgs:   1,
+		Args:      cmd.OnlyValidArgs,
+		ValidArgs: []string{"bash", "fish", "zsh"},
+		Run: func(c *cmd.Command, args []string) int {
+			fmt.Println("shell:", args[0])
+
+			return cmd.ExitSuccess
+		},
+	}
+	app := &cmd.Command{
+		Name:     "app",
+		Commands: []*cmd.Command{completion},
+	}
+
+	os.Args = []string{"app", "completion", "zsh"}
+	cmd.Run(app)
+	os.Args = []string{"app", "completion", "tcsh"}
+	status := cmd.Run(app)
+	fmt.Println("exit status:", status)
+
+	// Output:
+	// shell: zsh
+	// app completion: invalid argument "tcsh", expected one of bash, fish, zsh
+	// usage: app completion <shell>
+	// exit status: 2
+}
+
 func runChild(c *cmd.Command, args []string) int {
 	fmt.Printf("full command name: %q\n", c)
 	fmt.Println("-v flag:", *verbose)
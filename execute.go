@@ -0,0 +1,61 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/perillo/cmd/cmdstate"
+)
+
+// Execute is like Run, but it is a complete entry point for a program.  It
+// runs main with a context canceled by cmdstate.NotifyContext, recovers a
+// panic of the command as an ExitFailure status, and calls the functions
+// registered by cmdstate.AtExit.  It returns the status returned by Run or the
+// exit status set with cmdstate.SetExitStatus, whichever is greater, without
+// calling os.Exit.  Unlike Run, the exit status for -help is ExitSuccess.
+//
+// A typical use is:
+//
+//	func main() {
+//		os.Exit(cmd.Execute(mainCmd))
+//	}
+func Execute(main *Command) int {
+	ctx, stop := cmdstate.NotifyContext()
+	status := safeRun(ctx, main)
+	stop()
+	cmdstate.RunAtExit()
+
+	if code := cmdstate.GetExitStatus(); code > status {
+		status = code
+	}
+
+	return status
+}
+
+// safeRun is like RunWithContext, but it returns ExitSuccess for -help and
+// ExitFailure in case of panic.
+func safeRun(ctx context.Context, main *Command) (status int) {
+	defer func() {
+		if v := recover(); v != nil {
+			main.printError(fmt.Errorf("panic: %v", v))
+			print(string(debug.Stack()))
+			status = ExitFailure
+		}
+	}()
+
+	record(os.Args)
+	cmd, err := Parse(main, os.Args[1:])
+	status = dispatch(ctx, main, cmd, err, os.Args[0])
+	if err == flag.ErrHelp {
+		return ExitSuccess
+	}
+
+	return status
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/perillo/cmd/cmdstate"
+)
+
+// TestExecute tests that Execute returns the command status and runs the
+// functions registered by cmdstate.AtExit, after the command returns.
+func TestExecute(t *testing.T) {
+	defer restoreArgs()()
+
+	var calls []string
+	main := build(list{"test", "cmd"})
+	main.Commands[0].RunContext = func(ctx context.Context, cmd *Command,
+		args []string) int {
+		if ctx.Done() == nil {
+			t.Errorf("context is not cancelable")
+		}
+		cmdstate.AtExit(func() { calls = append(calls, "cleanup") })
+		calls = append(calls, "run")
+
+		return 3
+	}
+
+	os.Args = list{"test", "cmd"}
+	if status := Execute(main); status != 3 {
+		t.Errorf("got status %d, want %d", status, 3)
+	}
+	if got, want := join(calls), "run cleanup"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestExecutePanic tests that Execute recovers a panic of the command,
+// returning ExitFailure.
+func TestExecutePanic(t *testing.T) {
+	defer restoreArgs()()
+
+	cleanup := false
+	main := build(list{"test", "cmd"})
+	main.Commands[0].Run = func(cmd *Command, args []string) int {
+		cmdstate.AtExit(func() { cleanup = true })
+		panic("boom")
+	}
+
+	os.Args = list{"test", "cmd"}
+	status := 0
+	output := capture(t, func() {
+		status = Execute(main)
+	})
+	if status != ExitFailure {
+		t.Errorf("got status %d, want %d", status, ExitFailure)
+	}
+	if want := "test: panic: boom\n"; !strings.HasPrefix(output, want) {
+		t.Errorf("got output %q, want prefix %q", output, want)
+	}
+	if !cleanup {
+		t.Errorf("AtExit function not called")
+	}
+}
+
+// TestExecuteStatus tests the exit status returned by Execute for -help, for
+// an unknown command and for a failing command.
+func TestExecuteStatus(t *testing.T) {
+	defer restoreArgs()()
+
+	var tests = []struct {
+		argv   list
+		status int // expected exit status
+	}{
+		{list{"test", "-h"}, ExitSuccess},
+		{list{"test", "cmd", "-h"}, ExitSuccess},
+		{list{"test", "bad"}, ExitUsageError},
+		{list{"test", "cmd", "-bad"}, ExitUsageError},
+		{list{"test", "cmd"}, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := build(list{"test", "cmd"})
+			main.Commands[0].Run = func(cmd *Command, args []string) int {
+				return 3
+			}
+
+			os.Args = test.argv
+			status := 0
+			capture(t, func() {
+				status = Execute(main)
+			})
+			if status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+}
+
+// TestExecuteExitCode tests the exit code of a program exiting with the status
+// returned by Execute.  Each test is run in a new process executing the test
+// binary.
+func TestExecuteExitCode(t *testing.T) {
+	const key = "CMD_TEST_EXECUTE"
+
+	var tests = []struct {
+		argv list
+		code int // expected exit code
+	}{
+		{list{"test", "-h"}, ExitSuccess},
+		{list{"test", "bad"}, ExitUsageError},
+		{list{"test", "cmd"}, 3},
+		{list{"test", "status"}, 4},
+	}
+
+	if s := os.Getenv(key); s != "" {
+		i, _ := strconv.Atoi(s)
+		main := build(list{"test", "cmd"})
+		main.Commands[0].Run = func(cmd *Command, args []string) int {
+			return 3
+		}
+		main.Commands = append(main.Commands, &Command{
+			Name: "status",
+			Run: func(cmd *Command, args []string) int {
+				cmdstate.SetExitStatus(4)
+
+				return ExitFailure
+			},
+		})
+		os.Args = tests[i].argv
+		os.Exit(Execute(main))
+	}
+
+	for i, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestExecuteExitCode$")
+			cmd.Env = append(os.Environ(), key+"="+strconv.Itoa(i))
+			out, err := cmd.CombinedOutput()
+			code := ExitSuccess
+			if e, ok := err.(*exec.ExitError); ok {
+				code = e.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != test.code {
+				t.Errorf("got exit code %d, want %d: %s", code, test.code, out)
+			}
+		})
+	}
+}
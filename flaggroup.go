@@ -0,0 +1,140 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MarkFlagRequired marks the named flag as required, so that Run reports a
+// usage error if it is not set on the command-line.  The flag is annotated as
+// required in the default usage output.  The flag can be defined in Flag or
+// PersistentFlag, and a required persistent flag is also required by the sub
+// commands.  MarkFlagRequired panics if the flag is not defined.
+func (c *Command) MarkFlagRequired(name string) {
+	if c.lookupFlag(name) == nil {
+		panic(fmt.Sprintf("cmd: required flag -%s not defined", name))
+	}
+	if c.requiredFlags == nil {
+		c.requiredFlags = make(map[string]bool)
+	}
+	c.requiredFlags[name] = true
+}
+
+// MarkFlagsMutuallyExclusive marks the named flags as mutually exclusive, so
+// that Run reports a usage error if more than one of them is set on the
+// command-line, even to the default value.  The flags can be defined in Flag,
+// PersistentFlag or in the PersistentFlag of a parent.  Run panics if a flag
+// is not defined.
+func (c *Command) MarkFlagsMutuallyExclusive(names ...string) {
+	group := append([]string(nil), names...)
+	c.exclusiveFlags = append(c.exclusiveFlags, group)
+}
+
+// MarkFlagsRequiredTogether marks the named flags as required together, so
+// that Run reports a usage error if some of them, but not all, are set on the
+// command-line.  The flags are defined as in MarkFlagsMutuallyExclusive, and
+// the two kinds of group can be used on the same flags.
+func (c *Command) MarkFlagsRequiredTogether(names ...string) {
+	group := append([]string(nil), names...)
+	c.togetherFlags = append(c.togetherFlags, group)
+}
+
+// checkFlags checks that the flags set on the command-line satisfy the
+// required flags and the flag groups of c.  The required flags can also be set
+// from the environment.  It must be called after the persistent flags are
+// merged.
+func (c *Command) checkFlags() error {
+	set := c.setFlags(true)
+	var missing []string
+	for name := range c.requiredFlags {
+		if !set[name] {
+			missing = append(missing, "-"+name)
+		}
+	}
+	sort.Strings(missing)
+	switch len(missing) {
+	case 0:
+	case 1:
+		return fmt.Errorf("required flag %s not set", missing[0])
+	default:
+		return fmt.Errorf("required flags %s not set",
+			strings.Join(missing, ", "))
+	}
+
+	set = c.setFlags(false)
+	for _, group := range c.exclusiveFlags {
+		var names []string
+		for _, name := range group {
+			c.mustLookupFlag(name)
+			if set[name] {
+				names = append(names, "-"+name)
+			}
+		}
+		if len(names) > 1 {
+			return fmt.Errorf("flags %s are mutually exclusive",
+				strings.Join(names, ", "))
+		}
+	}
+	for _, group := range c.togetherFlags {
+		var names, missing []string
+		for _, name := range group {
+			c.mustLookupFlag(name)
+			names = append(names, "-"+name)
+			if !set[name] {
+				missing = append(missing, "-"+name)
+			}
+		}
+		if len(missing) > 0 && len(missing) < len(group) {
+			return fmt.Errorf("flags %s must be set together, missing %s",
+				strings.Join(names, ", "), strings.Join(missing, ", "))
+		}
+	}
+
+	return nil
+}
+
+// mustLookupFlag panics if the named flag is not defined in c.Flag.
+func (c *Command) mustLookupFlag(name string) {
+	if c.Flag.Lookup(name) == nil {
+		panic(fmt.Sprintf("cmd: flag -%s not defined", name))
+	}
+}
+
+// setFlags returns the set of flags of c set on the command-line, including
+// the persistent flags set when invoking its parents.  When env is true, the
+// set includes the flags set from the environment.
+func (c *Command) setFlags(env bool) map[string]bool {
+	set := make(map[string]bool)
+	c.Flag.Visit(func(f *flag.Flag) {
+		if env || !c.envSet[f.Name] {
+			set[f.Name] = true
+		}
+	})
+	c.VisitParents(func(p *Command) {
+		p.Flag.Visit(func(f *flag.Flag) {
+			if p.isPersistentFlag(f.Name) && (env || !p.envSet[f.Name]) {
+				set[f.Name] = true
+			}
+		})
+	})
+
+	return set
+}
+
+// isPersistentFlag reports whether the named flag is a persistent flag of c
+// or of one of its parents.
+func (c *Command) isPersistentFlag(name string) bool {
+	for p := c; p != nil; p = p.parent {
+		if p.PersistentFlag.Lookup(name) != nil {
+			return true
+		}
+	}
+
+	return false
+}
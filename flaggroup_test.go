@@ -0,0 +1,267 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// flagGroupTree returns the command tree used to test the flag groups, with
+// calls counting the runs of the sub command.
+func flagGroupTree(calls *int) *Command {
+	main := build(list{"test", "cmd"})
+	main.PersistentFlag.Bool("yaml", false, "yaml output")
+	cmd := main.Commands[0]
+	cmd.Flag.Bool("json", false, "json output")
+	cmd.Flag.Bool("text", false, "text output")
+	cmd.Run = func(cmd *Command, args []string) int {
+		*calls++
+
+		return ExitSuccess
+	}
+
+	return main
+}
+
+// TestRunMutuallyExclusiveFlags tests that Run reports a usage error when more
+// than one flag in a mutually exclusive group is set, even to the default
+// value.
+func TestRunMutuallyExclusiveFlags(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want string // expected error message
+	}{
+		{list{"app", "cmd"}, ""},
+		{list{"app", "cmd", "-json"}, ""},
+		{list{"app", "cmd", "-json=false", "-text"},
+			"app cmd: flags -json, -text are mutually exclusive\n"},
+		{list{"app", "cmd", "-json", "-text"},
+			"app cmd: flags -json, -text are mutually exclusive\n"},
+		{list{"app", "cmd", "-json", "-text", "-yaml"},
+			"app cmd: flags -json, -text, -yaml are mutually exclusive\n"},
+		{list{"app", "-yaml", "cmd", "-json"},
+			"app cmd: flags -json, -yaml are mutually exclusive\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			calls := 0
+			main := flagGroupTree(&calls)
+			cmd := main.Commands[0]
+			cmd.MarkFlagsMutuallyExclusive("json", "text", "yaml")
+
+			status := 0
+			output := capture(t, func() {
+				status = run(main, test.argv)
+			})
+			if test.want == "" {
+				if status != ExitSuccess || calls != 1 {
+					t.Errorf("got status %d and %d calls, output %q",
+						status, calls, output)
+				}
+
+				return
+			}
+			if status != ExitUsageError {
+				t.Errorf("got status %d, want %d", status, ExitUsageError)
+			}
+			if calls != 0 {
+				t.Errorf("got %d calls, want %d", calls, 0)
+			}
+			if !strings.HasPrefix(output, test.want+"usage: app cmd") {
+				t.Errorf("got output %q, want prefix %q", output, test.want)
+			}
+		})
+	}
+}
+
+// TestRunRequiredFlags tests that Run reports a usage error when a required
+// flag is not set, unless the help is requested.
+func TestRunRequiredFlags(t *testing.T) {
+	var tests = []struct {
+		argv   list
+		status int
+		want   string // expected output prefix
+	}{
+		{list{"app", "cmd", "-json", "-yaml"}, ExitSuccess, ""},
+		{list{"app", "-yaml", "cmd", "-json=false"}, ExitSuccess, ""},
+		{list{"app", "cmd", "-yaml"}, ExitUsageError,
+			"app cmd: required flag -json not set\n"},
+		{list{"app", "cmd"}, ExitUsageError,
+			"app cmd: required flags -json, -yaml not set\n"},
+		{list{"app", "cmd", "-h"}, ExitUsageError, "usage: app cmd"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			calls := 0
+			main := flagGroupTree(&calls)
+			main.MarkFlagRequired("yaml")
+			main.Commands[0].MarkFlagRequired("json")
+
+			status := 0
+			output := capture(t, func() {
+				status = run(main, test.argv)
+			})
+			if status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+			if !strings.HasPrefix(output, test.want) {
+				t.Errorf("got output %q, want prefix %q", output, test.want)
+			}
+			want := 0
+			if test.status == ExitSuccess {
+				want = 1
+			}
+			if calls != want {
+				t.Errorf("got %d calls, want %d", calls, want)
+			}
+		})
+	}
+
+	// A required persistent flag set by an intermediate command.
+	main := build(list{"app", "sub1", "sub2"})
+	main.PersistentFlag.String("v", "", "")
+	main.MarkFlagRequired("v")
+	main.Commands[0].Commands[0].Run = func(*Command, []string) int {
+		return ExitSuccess
+	}
+	status := 0
+	output := capture(t, func() {
+		status = run(main, list{"app", "sub1", "-v=cli", "sub2"})
+	})
+	if status != ExitSuccess {
+		t.Errorf("got status %d, want %d, output %q", status, ExitSuccess,
+			output)
+	}
+}
+
+// TestRequiredFlagUsage tests that the required flags are annotated in the
+// default usage output.
+func TestRequiredFlagUsage(t *testing.T) {
+	var cmd Command
+	cmd.Flag.String("name", "", "the `name`")
+	cmd.MarkFlagRequired("name")
+
+	want := "  -name name\n    \tthe name (required)\n"
+	if got := cmd.FlagUsages(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestRunFlagsRequiredTogether tests that Run reports a usage error when only
+// some of the flags in a group required together are set.
+func TestRunFlagsRequiredTogether(t *testing.T) {
+	var tests = []struct {
+		argv list
+		want string // expected error message
+	}{
+		{list{"app", "cmd"}, ""},
+		{list{"app", "cmd", "-json", "-text"}, ""},
+		{list{"app", "-yaml", "cmd"}, ""},
+		{list{"app", "cmd", "-json"}, "app cmd: flags -json, -text " +
+			"must be set together, missing -text\n"},
+		{list{"app", "cmd", "-text"}, "app cmd: flags -json, -text " +
+			"must be set together, missing -json\n"},
+		{list{"app", "cmd", "-json", "-text", "-yaml"},
+			"app cmd: flags -text, -yaml are mutually exclusive\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			calls := 0
+			main := flagGroupTree(&calls)
+			cmd := main.Commands[0]
+			cmd.MarkFlagsRequiredTogether("json", "text")
+			cmd.MarkFlagsMutuallyExclusive("text", "yaml")
+
+			status := 0
+			output := capture(t, func() {
+				status = run(main, test.argv)
+			})
+			if test.want == "" {
+				if status != ExitSuccess || calls != 1 {
+					t.Errorf("got status %d and %d calls, output %q",
+						status, calls, output)
+				}
+
+				return
+			}
+			if status != ExitUsageError {
+				t.Errorf("got status %d, want %d", status, ExitUsageError)
+			}
+			if !strings.HasPrefix(output, test.want+"usage: app cmd") {
+				t.Errorf("got output %q, want prefix %q", output, test.want)
+			}
+		})
+	}
+}
+
+// TestRunFlagGroupsEnv tests that the flags set from the environment satisfy
+// the required flags, but they are not considered by the flag groups.
+func TestRunFlagGroupsEnv(t *testing.T) {
+	var tests = []struct {
+		env  string // the variable set to 1
+		argv list
+		want string // expected error message
+	}{
+		{"MYTOOL_JSON", list{"app", "cmd", "-yaml"}, ""},
+		{"MYTOOL_YAML", list{"app", "cmd", "-json", "-text"}, ""},
+		{"MYTOOL_JSON", list{"app", "cmd", "-text"},
+			"app cmd: flags -json, -text must be set together, " +
+				"missing -json\n"},
+		{"MYTOOL_TEXT", list{"app", "cmd", "-json", "-yaml"},
+			"app cmd: flags -json, -yaml are mutually exclusive\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.env+":"+join(test.argv)), func(t *testing.T) {
+			os.Setenv(test.env, "1")
+			defer os.Unsetenv(test.env)
+
+			calls := 0
+			main := flagGroupTree(&calls)
+			main.AutoEnv("MYTOOL")
+			cmd := main.Commands[0]
+			cmd.MarkFlagsMutuallyExclusive("json", "yaml")
+			cmd.MarkFlagsRequiredTogether("json", "text")
+
+			status := 0
+			output := capture(t, func() {
+				status = run(main, test.argv)
+			})
+			if test.want == "" {
+				if status != ExitSuccess || calls != 1 {
+					t.Errorf("got status %d and %d calls, output %q",
+						status, calls, output)
+				}
+
+				return
+			}
+			if status != ExitUsageError {
+				t.Errorf("got status %d, want %d", status, ExitUsageError)
+			}
+			if !strings.HasPrefix(output, test.want+"usage: app cmd") {
+				t.Errorf("got output %q, want prefix %q", output, test.want)
+			}
+		})
+	}
+
+	// A required flag can be set from the environment.
+	os.Setenv("MYTOOL_TEXT", "1")
+	defer os.Unsetenv("MYTOOL_TEXT")
+	calls := 0
+	main := flagGroupTree(&calls)
+	main.AutoEnv("MYTOOL")
+	main.Commands[0].MarkFlagRequired("text")
+	output := capture(t, func() {
+		run(main, list{"app", "cmd"})
+	})
+	if calls != 1 {
+		t.Errorf("got %d calls, want %d, output %q", calls, 1, output)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/perillo/cmd/cmdstate"
+)
+
+// DebugFlagsEnv is the name of the environment variable that, when not empty,
+// reveals the hidden flags in a "debug flags:" section of the default usage
+// output.
+var DebugFlagsEnv = "CMD_DEBUG_FLAGS"
+
+// HideFlag marks the named flag as hidden, so that it will not be documented
+// in the default usage output, unless the DebugFlagsEnv environment variable
+// is set.  An hidden flag is still parsed normally.  The flag can be defined in
+// Flag or PersistentFlag.  HideFlag panics if the flag is not defined.
+func (c *Command) HideFlag(name string) {
+	if c.lookupFlag(name) == nil {
+		panic(fmt.Sprintf("cmd: hidden flag -%s not defined", name))
+	}
+	if c.hiddenFlags == nil {
+		c.hiddenFlags = make(map[string]bool)
+	}
+	c.hiddenFlags[name] = true
+}
+
+// FlagUsages returns the documentation of the visible flags of c, as printed
+// in the default usage output written to the output set by cmdstate.SetOutput.
+func (c *Command) FlagUsages() string {
+	var b strings.Builder
+	c.printFlags(&b, false, outputWidth(cmdstate.Output()))
+
+	return b.String()
+}
+
+// lookupFlag returns the named flag defined in c.Flag or c.PersistentFlag, or
+// nil if the flag is not defined.
+func (c *Command) lookupFlag(name string) *flag.Flag {
+	if f := c.Flag.Lookup(name); f != nil {
+		return f
+	}
+
+	return c.PersistentFlag.Lookup(name)
+}
+
+// mergePersistentFlags adds to c.Flag the persistent flags of c and of its
+// parents, that are not already defined, including their hidden, secret and
+// required marks.
+func (c *Command) mergePersistentFlags() {
+	for p := c; p != nil; p = p.parent {
+		p.PersistentFlag.VisitAll(func(f *flag.Flag) {
+			if c.Flag.Lookup(f.Name) != nil {
+				return
+			}
+			c.Flag.Var(f.Value, f.Name, f.Usage)
+
+			// Restore the default value, since it is set from the
+			// current value.
+			c.Flag.Lookup(f.Name).DefValue = f.DefValue
+			if p.hiddenFlags[f.Name] {
+				c.HideFlag(f.Name)
+			}
+			if p.secretFlags[f.Name] {
+				c.MarkFlagSecret(f.Name)
+			}
+			if p.requiredFlags[f.Name] {
+				c.MarkFlagRequired(f.Name)
+			}
+		})
+	}
+}
+
+// printFlags prints to w the default values of the flags in c.Flag, like
+// flag.FlagSet.PrintDefaults.  When hidden is true, only the hidden flags are
+// printed, otherwise only the visible ones.  The usage of a required flag is
+// annotated, and the usage messages are wrapped to width.
+func (c *Command) printFlags(w io.Writer, hidden bool, width int) {
+	c.Flag.VisitAll(func(f *flag.Flag) {
+		if c.hiddenFlags[f.Name] != hidden {
+			return
+		}
+		if c.requiredFlags[f.Name] {
+			required := *f
+			required.Usage += " (required)"
+			f = &required
+		}
+		printFlag(w, f, width)
+	})
+}
+
+// usageIndent is the indentation of the flag usage messages printed by
+// flag.FlagSet.PrintDefaults.
+const usageIndent = "    \t"
+
+// usageIndentWidth is the width of usageIndent, with the tab expanded.
+const usageIndentWidth = 8
+
+// printFlag prints to w the default value of f, using the same format as
+// flag.FlagSet.PrintDefaults, with the usage message wrapped to width.
+func printFlag(w io.Writer, f *flag.Flag, width int) {
+	var b strings.Builder
+	var fs flag.FlagSet
+	fs.SetOutput(&b)
+	fs.Var(f.Value, f.Name, f.Usage)
+
+	// Restore the default value, since it is set from the current value.
+	fs.Lookup(f.Name).DefValue = f.DefValue
+	fs.PrintDefaults()
+
+	width -= usageIndentWidth
+	lines := strings.SplitAfter(b.String(), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, usageIndent) {
+			continue
+		}
+		text := strings.TrimSuffix(line[len(usageIndent):], "\n")
+		text = wrap(text, width)
+		lines[i] = usageIndent + strings.Replace(text, "\n",
+			"\n"+usageIndent, -1) + "\n"
+	}
+	io.WriteString(w, strings.Join(lines, ""))
+}
+
+// showHiddenFlags reports whether the hidden flags of c should be documented
+// in the default usage output.
+func (c *Command) showHiddenFlags() bool {
+	return len(c.hiddenFlags) > 0 && os.Getenv(DebugFlagsEnv) != ""
+}
+
+// A defaultFunc is a function computing the default value of a flag.
+type defaultFunc struct {
+	name string
+	fn   func(c *Command) string
+}
+
+// resolveState is the resolution state of a dependent default value.
+type resolveState int
+
+const (
+	unresolved resolveState = iota
+	resolving
+	resolved
+)
+
+// SetFlagDefaultFunc sets fn as the function computing the default value of the
+// named flag, from the final state of the other flags.  After the flags are
+// parsed, fn is called if the flag was not set on the command-line, and the
+// returned value is set as the flag value.  SetFlagDefaultFunc panics if the
+// flag is not defined.
+//
+// The default functions are called in the order they are set.  A function
+// that reads a flag with another default function must use Command.FlagValue,
+// so that the other default value is resolved first.  Parse returns an error
+// if the default functions depend on each other in a cycle.
+func (c *Command) SetFlagDefaultFunc(name string, fn func(c *Command) string) {
+	if c.Flag.Lookup(name) == nil {
+		panic(fmt.Sprintf("cmd: flag -%s not defined", name))
+	}
+	c.defaultFuncs = append(c.defaultFuncs, defaultFunc{name, fn})
+}
+
+// FlagValue returns the current value of the named flag, as a string.  When
+// called while the dependent default values are being resolved, the flag
+// default value is resolved first, if necessary.  FlagValue panics if the flag
+// is not defined.
+func (c *Command) FlagValue(name string) string {
+	f := c.Flag.Lookup(name)
+	if f == nil {
+		panic(fmt.Sprintf("cmd: flag -%s not defined", name))
+	}
+	if c.resolving != nil && c.resolving[name] != resolved {
+		c.resolveDefault(name)
+	}
+
+	return f.Value.String()
+}
+
+// resolveDefaults sets the value of the flags not set on the command-line,
+// using the functions set by SetFlagDefaultFunc.
+func (c *Command) resolveDefaults() (err error) {
+	if len(c.defaultFuncs) == 0 {
+		return nil
+	}
+
+	// Flags set on the command-line are already resolved.
+	c.resolving = make(map[string]resolveState)
+	c.Flag.Visit(func(f *flag.Flag) {
+		c.resolving[f.Name] = resolved
+	})
+	defer func() {
+		c.resolving = nil
+		if v := recover(); v != nil {
+			e, ok := v.(resolveError)
+			if !ok {
+				panic(v)
+			}
+			err = e.err
+		}
+	}()
+
+	for _, df := range c.defaultFuncs {
+		if c.resolving[df.name] == unresolved {
+			c.resolveDefault(df.name)
+		}
+	}
+
+	return nil
+}
+
+// resolveError is used to report, via panic, an error resolving the dependent
+// default values.
+type resolveError struct {
+	err error
+}
+
+// resolveDefault sets the value of the named flag using its default function.
+func (c *Command) resolveDefault(name string) {
+	for _, df := range c.defaultFuncs {
+		if df.name != name {
+			continue
+		}
+		if c.resolving[name] == resolving {
+			err := fmt.Errorf("flag -%s: cycle in default values", name)
+			panic(resolveError{err})
+		}
+
+		c.resolving[name] = resolving
+		value := df.fn(c)
+		// Use Value.Set, since the flag must not be reported as set.
+		if err := c.Flag.Lookup(name).Value.Set(value); err != nil {
+			err = fmt.Errorf("invalid default value %q for flag -%s: %v",
+				value, name, err)
+			panic(resolveError{err})
+		}
+		c.resolving[name] = resolved
+
+		return
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestPrintFlags tests that Command.printFlags uses the same format as
+// flag.FlagSet.PrintDefaults.
+func TestPrintFlags(t *testing.T) {
+	var cmd Command
+	cmd.Flag.Bool("v", false, "verbose")
+	cmd.Flag.String("name", "default", "the `name` to use")
+	cmd.Flag.Int("n", 1, "number\nof items")
+	cmd.Flag.Duration("timeout", time.Second, "timeout")
+
+	// Set a value, to check that the default value is not changed.
+	cmd.Flag.Set("name", "value")
+
+	var got, want bytes.Buffer
+	cmd.printFlags(&got, false, outputWidth(&got))
+	cmd.Flag.SetOutput(&want)
+	cmd.Flag.PrintDefaults()
+	if got.String() != want.String() {
+		t.Errorf("got %q, want %q", got.String(), want.String())
+	}
+}
+
+// TestHideFlag tests that an hidden flag is parsed normally, but is only
+// documented in the default usage when DebugFlagsEnv is set.
+func TestHideFlag(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	cmd := main.Commands[0]
+	cmd.Flag.Bool("visible", false, "visible flag")
+	debug := cmd.Flag.Bool("debug", false, "debug flag")
+	cmd.HideFlag("debug")
+
+	if _, err := Parse(main, list{"cmd", "-debug"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !*debug {
+		t.Errorf("debug flag not set")
+	}
+
+	os.Unsetenv(DebugFlagsEnv)
+	want := "usage: test cmd \n  -visible\n    \tvisible flag\n"
+	if usage := render(t, cmd); usage != want {
+		t.Errorf("got usage %q, want %q", usage, want)
+	}
+
+	os.Setenv(DebugFlagsEnv, "1")
+	defer os.Unsetenv(DebugFlagsEnv)
+	want += "\ndebug flags:\n  -debug\n    \tdebug flag\n"
+	if usage := render(t, cmd); usage != want {
+		t.Errorf("got usage %q, want %q", usage, want)
+	}
+}
+
+// TestCommandFlagUsages tests that Command.FlagUsages returns the
+// documentation of the visible flags, without printing anything.
+func TestCommandFlagUsages(t *testing.T) {
+	main := build(list{"test"})
+	main.Flag.Bool("v", false, "verbose")
+	main.Flag.String("name", "main", "the `name`")
+	main.Flag.Bool("debug", false, "debug flag")
+	main.HideFlag("debug")
+
+	var got string
+	output := capture(t, func() {
+		got = main.FlagUsages()
+	})
+	if output != "" {
+		t.Errorf("unexpected output %q", output)
+	}
+	want := "  -name name\n    \tthe name (default \"main\")\n" +
+		"  -v\tverbose\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The output of the flag set is not changed.
+	var buf bytes.Buffer
+	main.Flag.SetOutput(&buf)
+	if got := main.FlagUsages(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if main.Flag.Output() != &buf || buf.Len() != 0 {
+		t.Errorf("flag set output changed, got %q written", buf.String())
+	}
+}
+
+// TestPersistentFlag tests that a persistent flag can be set on the command
+// defining it and on all its sub commands.
+func TestPersistentFlag(t *testing.T) {
+	var tests = []struct {
+		args list
+		want bool
+	}{
+		{list{"cmd", "a"}, false},
+		{list{"-v", "cmd", "a"}, true},
+		{list{"cmd", "-v", "a"}, true},
+		{list{"cmd", "a", "-v"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.args)), func(t *testing.T) {
+			main := build(list{"test", "cmd", "a"})
+			verbose := main.PersistentFlag.Bool("v", false, "verbose")
+			if _, err := Parse(main, test.args); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if *verbose != test.want {
+				t.Errorf("got %t, want %t", *verbose, test.want)
+			}
+		})
+	}
+}
+
+// TestPersistentFlagUsage tests that the persistent flags are documented in
+// the usage of the sub commands, with the original default value, and that
+// a flag defined by the sub command takes precedence.
+func TestPersistentFlagUsage(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	main.PersistentFlag.String("config", "app.conf", "config `file`")
+	main.PersistentFlag.Bool("debug", false, "debug flag")
+	main.HideFlag("debug")
+	cmd := main.Commands[0]
+	name := cmd.Flag.String("name", "cmd", "name")
+	main.PersistentFlag.String("name", "main", "name")
+
+	args := list{"-config", "x.conf", "cmd", "-name", "value"}
+	if _, err := Parse(main, args); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if *name != "value" {
+		t.Errorf("got %q, want %q", *name, "value")
+	}
+
+	os.Unsetenv(DebugFlagsEnv)
+	want := "usage: test cmd \n" +
+		"  -config file\n    \tconfig file (default \"app.conf\")\n" +
+		"  -name string\n    \tname (default \"cmd\")\n"
+	if usage := render(t, cmd); usage != want {
+		t.Errorf("got usage %q, want %q", usage, want)
+	}
+}
+
+// TestSetFlagDefaultFunc tests that the dependent default values are set after
+// parsing, only for the flags not set on the command-line.
+func TestSetFlagDefaultFunc(t *testing.T) {
+	var tests = []struct {
+		argv list
+		dir  string // expected -dir value
+		out  string // expected -out value
+	}{
+		{list{"cmd"}, "default", "default/out"},
+		{list{"cmd", "-name", "x"}, "x", "x/out"},
+		{list{"cmd", "-dir", "d"}, "d", "d/out"},
+		{list{"cmd", "-name", "x", "-out", "o"}, "x", "o"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := build(list{"test", "cmd"})
+			cmd := main.Commands[0]
+			name := cmd.Flag.String("name", "default", "name")
+			dir := cmd.Flag.String("dir", "", "directory")
+			out := cmd.Flag.String("out", "", "output")
+
+			// -out depends on -dir, that is resolved on demand.
+			cmd.SetFlagDefaultFunc("out", func(c *Command) string {
+				return c.FlagValue("dir") + "/out"
+			})
+			cmd.SetFlagDefaultFunc("dir", func(c *Command) string {
+				return *name
+			})
+
+			if _, err := Parse(main, test.argv); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if *dir != test.dir {
+				t.Errorf("got -dir %q, want %q", *dir, test.dir)
+			}
+			if *out != test.out {
+				t.Errorf("got -out %q, want %q", *out, test.out)
+			}
+		})
+	}
+}
+
+// TestSetFlagDefaultFuncCycle tests that Parse returns an error when the
+// dependent default values depend on each other in a cycle.
+func TestSetFlagDefaultFuncCycle(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	cmd := main.Commands[0]
+	cmd.Flag.String("a", "", "a")
+	cmd.Flag.String("b", "", "b")
+	cmd.SetFlagDefaultFunc("a", func(c *Command) string {
+		return c.FlagValue("b")
+	})
+	cmd.SetFlagDefaultFunc("b", func(c *Command) string {
+		return c.FlagValue("a")
+	})
+
+	_, err := Parse(main, list{"cmd"})
+	if want := "flag -a: cycle in default values"; err == nil ||
+		err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	// The cycle is broken when a flag is set on the command-line.
+	if _, err := Parse(main, list{"cmd", "-b", "x"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
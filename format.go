@@ -0,0 +1,66 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// siUnits indicates that FormatBytes uses SI units.
+var siUnits bool
+
+// SetSIUnits sets the units used by FormatBytes.  When si is true, SI units
+// (powers of 1000, like kB and MB) will be used instead of the default binary
+// units (powers of 1024, like KiB and MiB).
+func SetSIUnits(si bool) {
+	siUnits = si
+}
+
+// FormatBytes returns a human readable representation of n bytes, like
+// "512 B" or "1.5 MiB".  Sizes of at least one kilobyte are formatted with one
+// decimal digit.
+func FormatBytes(n int64) string {
+	unit, prefixes, suffix := 1024.0, "KMGTPE", "iB"
+	if siUnits {
+		unit, prefixes, suffix = 1000.0, "kMGTPE", "B"
+	}
+	if math.Abs(float64(n)) < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	// Use the next unit when the value would be rounded up to unit.
+	v := float64(n) / unit
+	i := 0
+	for math.Abs(v) >= unit-0.05 && i < len(prefixes)-1 {
+		v /= unit
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %c%s", v, prefixes[i], suffix)
+}
+
+// FormatDuration returns a human readable representation of d, like "2m3s" or
+// "1.5s".  The precision is reduced as the duration grows: durations of at
+// least one minute are rounded to the second, durations of at least one
+// second to the centisecond and durations of at least one millisecond to ten
+// microseconds.
+func FormatDuration(d time.Duration) string {
+	abs := d
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs >= time.Minute:
+		d = d.Round(time.Second)
+	case abs >= time.Second:
+		d = d.Round(10 * time.Millisecond)
+	case abs >= time.Millisecond:
+		d = d.Round(10 * time.Microsecond)
+	}
+
+	return d.String()
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFormatBytes tests the FormatBytes function, with binary and SI units.
+func TestFormatBytes(t *testing.T) {
+	defer SetSIUnits(false)
+
+	var tests = []struct {
+		n      int64
+		binary string
+		si     string
+	}{
+		{0, "0 B", "0 B"},
+		{999, "999 B", "999 B"},
+		{1000, "1000 B", "1.0 kB"},
+		{1023, "1023 B", "1.0 kB"},
+		{1024, "1.0 KiB", "1.0 kB"},
+		{1536, "1.5 KiB", "1.5 kB"},
+		{-1536, "-1.5 KiB", "-1.5 kB"},
+		{1048525, "1.0 MiB", "1.0 MB"}, // rounded up to the next unit
+		{1572864, "1.5 MiB", "1.6 MB"},
+		{1 << 40, "1.0 TiB", "1.1 TB"},
+		{1<<63 - 1, "8.0 EiB", "9.2 EB"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.binary, func(t *testing.T) {
+			SetSIUnits(false)
+			if got := FormatBytes(test.n); got != test.binary {
+				t.Errorf("got %q, want %q", got, test.binary)
+			}
+			SetSIUnits(true)
+			if got := FormatBytes(test.n); got != test.si {
+				t.Errorf("got %q, want %q (SI)", got, test.si)
+			}
+		})
+	}
+}
+
+// TestFormatDuration tests the FormatDuration function.
+func TestFormatDuration(t *testing.T) {
+	var tests = []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{500 * time.Nanosecond, "500ns"},
+		{1234567 * time.Nanosecond, "1.23ms"},
+		{1500 * time.Millisecond, "1.5s"},
+		{1999 * time.Millisecond, "2s"},
+		{123456 * time.Millisecond, "2m3s"},
+		{-123456 * time.Millisecond, "-2m3s"},
+		{time.Hour + 2*time.Minute + 3500*time.Millisecond, "1h2m4s"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := FormatDuration(test.d); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import "io"
+
+// A HelpRenderer renders the usage of a command.
+type HelpRenderer interface {
+	// Render writes the usage of c to w.
+	Render(c *Command, w io.Writer) error
+}
+
+// DefaultHelpRenderer is the default HelpRenderer.  It prints UsageLine,
+// followed by the flag defaults, Long, the list of available sub commands and
+// help topics, and the usage footer, unless a template is set by
+// SetUsageTemplate.
+var DefaultHelpRenderer HelpRenderer = defaultRenderer{}
+
+// helpRenderer is the HelpRenderer used by commands without a Usage function.
+var helpRenderer = DefaultHelpRenderer
+
+// SetHelpRenderer sets the HelpRenderer used by the commands that do not
+// specify a Usage function.  A nil r restores DefaultHelpRenderer.
+func SetHelpRenderer(r HelpRenderer) {
+	if r == nil {
+		r = DefaultHelpRenderer
+	}
+	helpRenderer = r
+}
+
+// defaultRenderer implements DefaultHelpRenderer.
+type defaultRenderer struct{}
+
+func (defaultRenderer) Render(c *Command, w io.Writer) error {
+	return c.defaultUsage(w)
+}
+
+// HelpCommand returns a help command, that can be added to the sub commands
+// of main.  'help' prints the usage of the parent command, 'help command...'
+// prints the usage of the named command and 'help topic' prints the body of
+// the named help topic.
+func HelpCommand() *Command {
+	return &Command{
+		Name:      "help",
+		UsageLine: "[command...] | [topic]",
+		Short:     "show help for a command or topic",
+		Run:       runHelp,
+	}
+}
+
+// runHelp implements the help command.
+func runHelp(help *Command, args []string) int {
+	parent := help.parent
+	if parent == nil {
+		// The help command has not been invoked by Parse.
+		parent = help
+	}
+	root := parent.root()
+
+	cmd := parent
+	for i, name := range args {
+		sub := cmd.lookup(root, name)
+		if sub != nil {
+			cmd = sub
+
+			continue
+		}
+		if i == len(args)-1 {
+			if topic := cmd.topic(name); topic != nil {
+				printf("%s\n", topic.Long)
+
+				return ExitSuccess
+			}
+		}
+		cmd.printUnknown(name, "unknown command or topic")
+		printf("Run '%s help' for usage.\n", parent)
+
+		return ExitUsageError
+	}
+	cmd.usage()
+
+	return ExitSuccess
+}
+
+// topic returns the help topic of c with the specified name, or nil if no
+// such topic exists.
+func (c *Command) topic(name string) *HelpTopic {
+	for _, topic := range c.Topics {
+		if topic.Name == name {
+			return topic
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/perillo/cmd/cmdstate"
+)
+
+// nameRenderer is a HelpRenderer that only renders the command name.
+type nameRenderer struct{}
+
+func (nameRenderer) Render(c *Command, w io.Writer) error {
+	_, err := fmt.Fprintf(w, "help for %s\n", c)
+
+	return err
+}
+
+// TestSetHelpRenderer tests that the help renderer set by SetHelpRenderer is
+// used to print the usage of commands without a Usage function.
+func TestSetHelpRenderer(t *testing.T) {
+	defer SetHelpRenderer(nil)
+	SetHelpRenderer(nameRenderer{})
+
+	main := build(list{"test", "cmd"})
+	output := capture(t, func() {
+		run(main, list{"app", "cmd", "-h"})
+	})
+	if want := "help for app cmd\n"; output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+
+	// Restore the default renderer.
+	SetHelpRenderer(nil)
+	output = capture(t, func() {
+		run(main, list{"app", "cmd", "-h"})
+	})
+	if want := "usage: app cmd \n"; output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+}
+
+// TestUsageNameColumn tests with a golden file that the name column of the
+// default usage output is aligned to the longest name, up to maxNameWidth.
+func TestUsageNameColumn(t *testing.T) {
+	main := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{Name: "a", Short: "short name"},
+			{Name: "remote", Short: "medium name"},
+			{Name: "configuration", Short: "long name"},
+			{Name: "secret-very-long-command-name", Hidden: true},
+		},
+		Topics: []*HelpTopic{
+			{Name: "environment", Short: "topic name"},
+		},
+	}
+	golden(t, "usage_names.txt", []byte(render(t, main)))
+
+	main.Commands[3].Hidden = false
+	main.Commands[3].Short = "longer than the maximum width"
+	golden(t, "usage_long_names.txt", []byte(render(t, main)))
+}
+
+// TestUsageGroups tests that the default usage output lists the sub commands
+// by group, in the order the groups are first declared.
+func TestUsageGroups(t *testing.T) {
+	main := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{Name: "add", Short: "add a remote", Group: "remote commands"},
+			{Name: "version", Short: "print the version"},
+			{Name: "status", Short: "show the status", Group: "main commands"},
+			{Name: "remove", Short: "remove a remote",
+				Group: "remote commands"},
+			{Name: "debug", Group: "debug commands", Hidden: true},
+		},
+	}
+
+	want := strings.Join([]string{
+		"usage: app ",
+		"",
+		"remote commands:",
+		"",
+		"\tadd     add a remote",
+		"\tremove  remove a remote",
+		"",
+		"main commands:",
+		"",
+		"\tstatus  show the status",
+		"",
+		"other commands:",
+		"",
+		"\tversion print the version",
+		"",
+	}, "\n")
+	if got := render(t, main); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestUsageExamples tests that the default usage output shows the examples
+// after Long, and omits the section when there are no examples.
+func TestUsageExamples(t *testing.T) {
+	cmd := &Command{
+		Name: "add",
+		Long: "Add adds a remote.",
+	}
+	want := "usage: add \n\nAdd adds a remote.\n"
+	if got := render(t, cmd); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	cmd.Examples = []Example{
+		{Desc: "add the origin remote", Command: "add origin https://host"},
+		{Command: "add -v backup /mnt/backup"},
+	}
+	want = strings.Join([]string{
+		"usage: add ",
+		"",
+		"Add adds a remote.",
+		"",
+		"examples:",
+		"",
+		"\t# add the origin remote",
+		"\tadd origin https://host",
+		"",
+		"\tadd -v backup /mnt/backup",
+		"",
+	}, "\n")
+	if got := render(t, cmd); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestCommandWriteUsage tests that Command.WriteUsage writes the usage to the
+// specified writer.
+func TestCommandWriteUsage(t *testing.T) {
+	main := build(list{"test", "cmd"})
+	main.Flag.Bool("v", false, "verbose")
+
+	var b bytes.Buffer
+	output := capture(t, func() {
+		main.WriteUsage(&b)
+	})
+	if output != "" {
+		t.Errorf("unexpected output %q", output)
+	}
+	want := "usage: test \n  -v\tverbose\n\ncommands:\n\n\tcmd \n"
+	if got := b.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestSetOutput tests that the usage and the error messages are written to
+// the destination set by cmdstate.SetOutput.
+func TestSetOutput(t *testing.T) {
+	defer cmdstate.SetOutput(nil)
+	var buf bytes.Buffer
+	cmdstate.SetOutput(&buf)
+
+	main := build(list{"test", "cmd"})
+	output := capture(t, func() {
+		run(main, list{"app", "cmd", "-h"})
+		run(main, list{"app", "x"})
+	})
+	if output != "" {
+		t.Errorf("unexpected output %q", output)
+	}
+	want := "usage: app cmd \n" +
+		"app x: unknown command\nRun 'app -help' for usage.\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestHelpCommand tests the command returned by HelpCommand.
+func TestHelpCommand(t *testing.T) {
+	var tests = []struct {
+		args   list
+		code   int
+		output string
+	}{
+		{list{}, ExitSuccess, "usage: test \n\ncommands:\n\n" +
+			"\tcmd   \n" +
+			"\thelp  show help for a command or topic\n\n" +
+			"additional help topics:\n\n\ttopic about topic\n"},
+		{list{"cmd"}, ExitSuccess, "usage: test cmd \n"},
+		{list{"help"}, ExitSuccess,
+			"usage: test help [command...] | [topic]\n"},
+		{list{"topic"}, ExitSuccess, "about topic\n"},
+		{list{"bogus"}, ExitUsageError,
+			"test bogus: unknown command or topic\n" +
+				"Run 'test help' for usage.\n"},
+		{list{"cmd", "topic"}, ExitUsageError,
+			"test cmd topic: unknown command or topic\n" +
+				"Run 'test help' for usage.\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.args)), func(t *testing.T) {
+			main := build(list{"test", "cmd"})
+			main.Commands = append(main.Commands, HelpCommand())
+			main.Topics = []*HelpTopic{{Name: "topic", Long: "about topic"}}
+
+			var code int
+			output := capture(t, func() {
+				argv := append(list{"test", "help"}, test.args...)
+				code = run(main, argv)
+			})
+			if code != test.code {
+				t.Errorf("got status %d, want %d", code, test.code)
+			}
+			if output != test.output {
+				t.Errorf("got output %q, want %q", output, test.output)
+			}
+		})
+	}
+}
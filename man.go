@@ -0,0 +1,168 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DocOptions configures the documentation written by GenManPageWithOptions
+// and GenMarkdownTreeWithOptions.  The zero value is the default used by
+// GenManPage and GenMarkdownTree.
+type DocOptions struct {
+	// DisableAutoGenTag omits the "Auto generated by" line, with its date,
+	// so that the documentation is reproducible.
+	DisableAutoGenTag bool
+
+	// Date is the date of the "Auto generated by" line.  The zero value
+	// means the current date.
+	Date time.Time
+}
+
+// autoGenTag returns the "Auto generated by" line of the documentation of the
+// main command named name, or an empty string if disabled.
+func (o DocOptions) autoGenTag(name string) string {
+	if o.DisableAutoGenTag {
+		return ""
+	}
+	date := o.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	return fmt.Sprintf("Auto generated by %s on %s.", name,
+		date.Format("2-Jan-2006"))
+}
+
+// GenManPage writes to w a section 1 man page for c, in roff format.  The
+// page documents the usage line, Long, the visible flags of c, including the
+// persistent flags of its parents, its sub commands, the usage footer and an
+// "Auto generated by" line with the current date.  The page of a sub command
+// is named after its full name, with spaces replaced by '-', like git-remote.
+//
+// The page can be viewed with:
+//
+//	app manpage > app.1 && man -l app.1
+func (c *Command) GenManPage(w io.Writer) error {
+	return c.GenManPageWithOptions(w, DocOptions{})
+}
+
+// GenManPageWithOptions is like GenManPage, but configured by opts.
+func (c *Command) GenManPageWithOptions(w io.Writer, opts DocOptions) error {
+	var b bytes.Buffer
+	name := strings.Replace(c.String(), " ", "-", -1)
+	fmt.Fprintf(&b, ".TH %s 1", roffQuote(strings.ToUpper(name)))
+	if root := c.root(); root.Version != "" {
+		source := root.Name + " " + root.Version
+		fmt.Fprintf(&b, ` "" %s`, roffQuote(source))
+	}
+	fmt.Fprint(&b, "\n")
+
+	fmt.Fprint(&b, ".SH NAME\n")
+	if short := c.ShortDescription(); short != "" {
+		fmt.Fprintf(&b, "%s \\- %s\n", roffEscape(name), roffEscape(short))
+	} else {
+		fmt.Fprintf(&b, "%s\n", roffEscape(name))
+	}
+
+	fmt.Fprint(&b, ".SH SYNOPSIS\n")
+	fmt.Fprintf(&b, ".B %s\n", roffEscape(c.String()))
+	if c.UsageLine != "" {
+		fmt.Fprintf(&b, "%s\n", roffEscape(c.UsageLine))
+	}
+
+	if long := strings.TrimSpace(c.Long); long != "" {
+		fmt.Fprint(&b, ".SH DESCRIPTION\n")
+		roffParagraphs(&b, long)
+	}
+
+	if flags := visibleFlags(commandPath(c)); len(flags) > 0 {
+		fmt.Fprint(&b, ".SH FLAGS\n")
+		for _, f := range flags {
+			name, usage := flag.UnquoteUsage(f)
+			fmt.Fprint(&b, ".TP\n")
+			fmt.Fprintf(&b, "\\fB%s\\fR", roffEscape("-"+f.Name))
+			if name != "" {
+				fmt.Fprintf(&b, " \\fI%s\\fR", roffEscape(name))
+			}
+			fmt.Fprint(&b, "\n")
+			if !isZeroDefault(f.DefValue) {
+				usage += fmt.Sprintf(" (default %s)", f.DefValue)
+			}
+			fmt.Fprintf(&b, "%s\n", roffEscape(usage))
+		}
+	}
+
+	if subs := visibleCommands(c); len(subs) > 0 {
+		fmt.Fprint(&b, ".SH COMMANDS\n")
+		for _, sub := range subs {
+			fmt.Fprint(&b, ".TP\n")
+			fmt.Fprintf(&b, ".B %s\n", roffEscape(sub.Name))
+			fmt.Fprintf(&b, "%s\n", roffEscape(sub.ShortDescription()))
+		}
+	}
+
+	if footer := strings.TrimSpace(c.footer()); footer != "" {
+		fmt.Fprint(&b, ".SH NOTES\n")
+		roffParagraphs(&b, footer)
+	}
+
+	if tag := opts.autoGenTag(c.root().Name); tag != "" {
+		fmt.Fprint(&b, ".SH HISTORY\n")
+		fmt.Fprintf(&b, "%s\n", roffEscape(tag))
+	}
+
+	_, err := w.Write(b.Bytes())
+
+	return err
+}
+
+// roffParagraphs writes to b the paragraphs of text, separated by empty lines,
+// as roff text.
+func roffParagraphs(b *bytes.Buffer, text string) {
+	for i, para := range strings.Split(text, "\n\n") {
+		if i > 0 {
+			fmt.Fprint(b, ".PP\n")
+		}
+		fmt.Fprintf(b, "%s\n", roffEscape(strings.TrimSpace(para)))
+	}
+}
+
+// isZeroDefault reports whether value is the zero default value of a flag,
+// that is not documented.
+func isZeroDefault(value string) bool {
+	switch value {
+	case "", "false", "0":
+		return true
+	}
+
+	return false
+}
+
+// roffEscape returns s escaped as roff text: the backslashes and the hyphens
+// are escaped, and a line starting with a control character is prefixed by
+// the zero width \& escape.
+func roffEscape(s string) string {
+	s = strings.Replace(s, `\`, `\e`, -1)
+	s = strings.Replace(s, "-", `\-`, -1)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// roffQuote returns s escaped and quoted as a roff macro argument.
+func roffQuote(s string) string {
+	return `"` + strings.Replace(roffEscape(s), `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestGenManPage tests the man page with a golden file.
+func TestGenManPage(t *testing.T) {
+	main := completionTree()
+	main.Version = "1.0" // documented in the title
+	main.UsageLine = "[flags] command [args]"
+	main.Long = `App manages the remote repositories.
+
+Run 'app help command' for more information.
+.This line starts with a dot, and C:\Windows has a backslash.`
+	main.Flag.Int("n", 10, "number of `jobs`")
+	main.Footer = "Report bugs to <bugs@example.com>."
+
+	opts := DocOptions{Date: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+	var page bytes.Buffer
+	if err := main.GenManPageWithOptions(&page, opts); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "app.1", page.Bytes())
+
+	// The "Auto generated by" line can be disabled.
+	opts.DisableAutoGenTag = true
+	var plain bytes.Buffer
+	if err := main.GenManPageWithOptions(&plain, opts); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(plain.Bytes(), []byte("Auto generated")) {
+		t.Errorf("got auto generated tag in %q", plain.String())
+	}
+}
+
+// TestRoffEscape tests the escaping of roff text.
+func TestRoffEscape(t *testing.T) {
+	var tests = []struct {
+		s    string
+		want string
+	}{
+		{"text", "text"},
+		{"-flag", `\-flag`},
+		{`a\b`, `a\eb`},
+		{".TH", `\&.TH`},
+		{"'quote", `\&'quote`},
+		{"a\n.b\nc", "a\n\\&.b\nc"},
+		{"a.b 'c'", "a.b 'c'"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.s), func(t *testing.T) {
+			if got := roffEscape(test.s); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// GenMarkdownTree writes to dir a Markdown page for c and for each of its
+// visible sub commands.  A page documents the usage line, Long, the visible
+// flags, the usage footer, links to the pages of the parent and of the sub
+// commands and an "Auto generated by" line with the current date.
+//
+// Each page is named after the full name of the command, with spaces replaced
+// by '_', like app_remote_add.md, so that commands with the same name in
+// different positions of the command tree are written to different files.  The
+// '_' and '%' characters in a command name are escaped as %5F and %25, so that
+// app remote_add is written to app_remote%5Fadd.md.  GenMarkdownTree returns
+// an error if a command name contains a path separator.
+func (c *Command) GenMarkdownTree(dir string) error {
+	return c.GenMarkdownTreeWithOptions(dir, DocOptions{})
+}
+
+// GenMarkdownTreeWithOptions is like GenMarkdownTree, but configured by opts.
+func (c *Command) GenMarkdownTreeWithOptions(dir string,
+	opts DocOptions) error {
+	var err error
+	parents := commandPath(c)
+	parents = parents[:len(parents)-1]
+	walkVisible(c, parents, func(path []*Command) {
+		if err != nil {
+			return
+		}
+		for _, cmd := range path {
+			if strings.ContainsAny(cmd.Name, "/"+string(filepath.Separator)) {
+				err = fmt.Errorf("invalid command name %q for a file name",
+					cmd.Name)
+
+				return
+			}
+		}
+		file := filepath.Join(dir, markdownFile(path))
+		err = ioutil.WriteFile(file, genMarkdown(path, opts), 0666)
+	})
+
+	return err
+}
+
+// genMarkdown returns the Markdown page of the command at the end of path,
+// configured by opts.
+func genMarkdown(path []*Command, opts DocOptions) []byte {
+	var b bytes.Buffer
+	cmd := path[len(path)-1]
+	fmt.Fprintf(&b, "# %s\n\n", pathName(path))
+	if short := cmd.ShortDescription(); short != "" {
+		fmt.Fprintf(&b, "%s\n\n", short)
+	}
+
+	fmt.Fprint(&b, "## Usage\n\n")
+	fmt.Fprintf(&b, "```\n%s %s\n```\n\n", pathName(path), cmd.UsageLine)
+	if long := strings.TrimSpace(cmd.Long); long != "" {
+		fmt.Fprintf(&b, "%s\n\n", long)
+	}
+
+	if flags := visibleFlags(path); len(flags) > 0 {
+		fmt.Fprint(&b, "## Flags\n\n")
+		fmt.Fprint(&b, "| Flag | Description | Default |\n")
+		fmt.Fprint(&b, "| ---- | ----------- | ------- |\n")
+		for _, f := range flags {
+			name, usage := flag.UnquoteUsage(f)
+			spec := "-" + f.Name
+			if name != "" {
+				spec += " " + name
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", spec,
+				markdownCell(usage), markdownDefault(f.DefValue))
+		}
+		fmt.Fprint(&b, "\n")
+	}
+
+	if subs := visibleCommands(cmd); len(subs) > 0 {
+		fmt.Fprint(&b, "## Commands\n\n")
+		for _, sub := range subs {
+			subpath := append(path[:len(path):len(path)], sub)
+			markdownLink(&b, subpath)
+		}
+		fmt.Fprint(&b, "\n")
+	}
+
+	if footer := strings.TrimSpace(cmd.footer()); footer != "" {
+		fmt.Fprintf(&b, "%s\n\n", footer)
+	}
+
+	if len(path) > 1 {
+		fmt.Fprint(&b, "## See also\n\n")
+		markdownLink(&b, path[:len(path)-1])
+		fmt.Fprint(&b, "\n")
+	}
+
+	if tag := opts.autoGenTag(path[0].Name); tag != "" {
+		fmt.Fprintf(&b, "*%s*\n\n", tag)
+	}
+
+	// Remove the trailing empty line.
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// markdownFile returns the file name of the Markdown page of the command at
+// the end of path.
+func markdownFile(path []*Command) string {
+	names := make([]string, len(path))
+	for i, cmd := range path {
+		names[i] = fileNameEscaper.Replace(cmd.Name)
+	}
+
+	return strings.Join(names, "_") + ".md"
+}
+
+// fileNameEscaper escapes the characters of a command name that would make the
+// Markdown file names ambiguous.
+var fileNameEscaper = strings.NewReplacer("%", "%25", "_", "%5F")
+
+// markdownLink writes to b a list item with a link to the Markdown page of the
+// command at the end of path, followed by its short description.
+func markdownLink(b *bytes.Buffer, path []*Command) {
+	cmd := path[len(path)-1]
+	fmt.Fprintf(b, "* [%s](%s)", pathName(path),
+		url.PathEscape(markdownFile(path)))
+	if short := cmd.ShortDescription(); short != "" {
+		fmt.Fprintf(b, " - %s", short)
+	}
+	fmt.Fprint(b, "\n")
+}
+
+// markdownDefault returns the documented default value of a flag, as Markdown
+// code.
+func markdownDefault(value string) string {
+	if isZeroDefault(value) {
+		return ""
+	}
+
+	return "`" + markdownCell(value) + "`"
+}
+
+// markdownCell returns s escaped for a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+
+	return strings.Replace(s, "\n", " ", -1)
+}
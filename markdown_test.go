@@ -0,0 +1,147 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGenMarkdownTree tests that a page is written for each visible command,
+// and that commands with the same name do not collide.
+func TestGenMarkdownTree(t *testing.T) {
+	main := completionTree()
+	main.Commands = append(main.Commands, &Command{
+		Name:     "config",
+		Short:    "manage the configuration",
+		Commands: []*Command{{Name: "add", Short: "add an option"}},
+	})
+	remote := main.Commands[0]
+	remote.UsageLine = "command [args]"
+	remote.Long = "Remote manages the | separated remotes."
+	remote.Flag.Int("n", 10, "number of `jobs`")
+	remote.Footer = "Run 'app help remote' for more information."
+
+	dir, err := ioutil.TempDir("", "markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := DocOptions{Date: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+	if err := main.GenMarkdownTreeWithOptions(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	want := list{
+		"app.md", "app_config.md", "app_config_add.md", "app_remote.md",
+		"app_remote_add.md", "app_remote_remove.md",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want %q", names, want)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "app_remote.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "app_remote.md", data)
+}
+
+// TestGenMarkdownTreeAutoGenTag tests that the "Auto generated by" line is
+// written by default, and that it can be disabled.
+func TestGenMarkdownTreeAutoGenTag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var tests = []struct {
+		disable bool
+		want    bool // expected tag
+	}{
+		{false, true},
+		{true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.disable), func(t *testing.T) {
+			main := &Command{Name: "app"}
+			opts := DocOptions{DisableAutoGenTag: test.disable}
+			if err := main.GenMarkdownTreeWithOptions(dir, opts); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, "app.md"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Contains(string(data), "*Auto generated by app on ")
+			if got != test.want {
+				t.Errorf("got tag %t, want %t in %q", got, test.want, data)
+			}
+		})
+	}
+}
+
+// TestMarkdownFile tests that the Markdown file names of different commands
+// do not collide.
+func TestMarkdownFile(t *testing.T) {
+	var tests = []struct {
+		names list
+		want  string
+	}{
+		{list{"app"}, "app.md"},
+		{list{"app", "remote", "add"}, "app_remote_add.md"},
+		{list{"app", "remote_add"}, "app_remote%5Fadd.md"},
+		{list{"app", "100%_done"}, "app_100%25%5Fdone.md"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.names)), func(t *testing.T) {
+			var path []*Command
+			for _, name := range test.names {
+				path = append(path, &Command{Name: name})
+			}
+			if got := markdownFile(path); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestGenMarkdownTreeInvalidName tests that GenMarkdownTree returns an error
+// for a command name containing a path separator.
+func TestGenMarkdownTreeInvalidName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	main := build(list{"app", "../evil"})
+	err = main.GenMarkdownTree(dir)
+	want := `invalid command name "../evil" for a file name`
+	if msg := errorString(err); msg != want {
+		t.Errorf("got error %q, want %q", msg, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "evil.md")); err == nil {
+		t.Errorf("file written outside of %s", dir)
+	}
+}
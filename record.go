@@ -0,0 +1,159 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// An invocation is a recorded command invocation.
+type invocation struct {
+	Args []string          `json:"args"`
+	Env  map[string]string `json:"env,omitempty"`
+}
+
+var recordMu sync.Mutex // guards recordFile and recordEnv
+var recordFile string
+var recordEnv []string
+
+// SetRecordFile enables the recording of each invocation of Run to the file
+// named by path, that will be created if necessary.  Each invocation is
+// appended as a JSON line containing the command-line and the value of the
+// specified environment variables, when set.  An empty path disables the
+// recording.
+func SetRecordFile(path string, env ...string) {
+	recordMu.Lock()
+	recordFile = path
+	recordEnv = env
+	recordMu.Unlock()
+}
+
+// record appends the invocation with the specified command-line to the record
+// file, if enabled.  Errors are reported to os.Stderr, but are otherwise
+// ignored.
+func record(argv []string) {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+
+	if recordFile == "" {
+		return
+	}
+	inv := invocation{Args: argv}
+	for _, key := range recordEnv {
+		if value, ok := os.LookupEnv(key); ok {
+			if inv.Env == nil {
+				inv.Env = make(map[string]string)
+			}
+			inv.Env[key] = value
+		}
+	}
+
+	if err := appendJSON(recordFile, inv); err != nil {
+		printf("record: %v\n", err)
+	}
+}
+
+// appendJSON appends v as a JSON line to the file named by path.
+func appendJSON(path string, v interface{}) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
+
+// Replay executes the sub commands of main, for each invocation recorded by
+// SetRecordFile in the file named by path.  The recorded environment variables
+// are set during each invocation, and restored afterwards.  It returns the
+// highest exit status returned by the invocations, or ExitFailure in case of
+// errors reading the file.
+//
+// Before each invocation, the flags of all the commands are reset to their
+// default value, so that the flag values of an invocation do not carry into
+// the next one.
+func Replay(main *Command, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		printf("replay: %v\n", err)
+
+		return ExitFailure
+	}
+	defer f.Close()
+
+	status := ExitSuccess
+	scanner := bufio.NewScanner(f)
+	for scanner.Buffer(nil, 1<<20); scanner.Scan(); {
+		var inv invocation
+		if err := json.Unmarshal(scanner.Bytes(), &inv); err != nil {
+			printf("replay: %s: %v\n", path, err)
+
+			return ExitFailure
+		}
+		if len(inv.Args) == 0 {
+			printf("replay: %s: empty command-line\n", path)
+
+			return ExitFailure
+		}
+
+		if code := replay(main, inv); code > status {
+			status = code
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		printf("replay: %v\n", err)
+
+		return ExitFailure
+	}
+
+	return status
+}
+
+// replay executes a single recorded invocation.
+func replay(main *Command, inv invocation) int {
+	if err := resetFlags(main); err != nil {
+		printf("replay: %v\n", err)
+
+		return ExitFailure
+	}
+	for key, value := range inv.Env {
+		old, ok := os.LookupEnv(key)
+		if ok {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+		os.Setenv(key, value)
+	}
+
+	return run(main, inv.Args)
+}
+
+// resetFlags sets the flags of main and of all its sub commands to their
+// default value.
+func resetFlags(main *Command) error {
+	return main.Walk(func(c *Command) error {
+		var err error
+		reset := func(f *flag.Flag) {
+			if e := f.Value.Set(f.DefValue); e != nil && err == nil {
+				err = fmt.Errorf("%s: cannot reset flag -%s: %v", c, f.Name, e)
+			}
+		}
+		c.Flag.VisitAll(reset)
+		c.PersistentFlag.VisitAll(reset)
+
+		return err
+	})
+}
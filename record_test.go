@@ -0,0 +1,134 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestRecordReplay tests that the invocations recorded by Run are executed
+// again by Replay, with the recorded environment.
+func TestRecordReplay(t *testing.T) {
+	const key = "CMD_TEST_RECORD"
+
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "record.json")
+
+	// Record two invocations.
+	defer restoreArgs()()
+	defer SetRecordFile("")
+	SetRecordFile(path, key)
+
+	var calls []list
+	var env []string
+	main := build(list{"test", "cmd"})
+	main.Commands[0].Run = func(cmd *Command, args []string) int {
+		calls = append(calls, args)
+		env = append(env, os.Getenv(key))
+
+		return len(args)
+	}
+
+	os.Setenv(key, "value")
+	os.Args = list{"test", "cmd", "a"}
+	Run(main)
+	os.Unsetenv(key)
+	os.Args = list{"test", "cmd", "b", "c"}
+	Run(main)
+
+	// Replay the invocations.
+	SetRecordFile("")
+	calls, env = nil, nil
+	status := Replay(main, path)
+	if status != 2 {
+		t.Errorf("got exit status %d, want %d", status, 2)
+	}
+	if want := []list{{"a"}, {"b", "c"}}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
+	if want := []string{"value", ""}; !reflect.DeepEqual(env, want) {
+		t.Errorf("got environment %q, want %q", env, want)
+	}
+	if _, ok := os.LookupEnv(key); ok {
+		t.Errorf("environment not restored")
+	}
+}
+
+// TestReplayFlags tests that Replay resets the flags to their default value
+// before each invocation.
+func TestReplayFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "record.json")
+
+	var values []string
+	newMain := func() *Command {
+		main := build(list{"test", "cmd"})
+		v := main.PersistentFlag.String("v", "", "")
+		n := main.Commands[0].Flag.Int("n", 0, "")
+		main.Commands[0].Run = func(cmd *Command, args []string) int {
+			values = append(values, fmt.Sprintf("%s %d", *v, *n))
+
+			return 0
+		}
+
+		return main
+	}
+
+	// Record two invocations, the second without flags.
+	defer restoreArgs()()
+	defer SetRecordFile("")
+	SetRecordFile(path)
+
+	os.Args = list{"test", "-v=x", "cmd", "-n=1"}
+	Run(newMain())
+	os.Args = list{"test", "cmd"}
+	Run(newMain())
+
+	// Replay the invocations with the same command tree.
+	SetRecordFile("")
+	values = nil
+	if status := Replay(newMain(), path); status != ExitSuccess {
+		t.Errorf("got exit status %d, want %d", status, ExitSuccess)
+	}
+	if want := []string{"x 1", " 0"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got flag values %q, want %q", values, want)
+	}
+}
+
+// TestReplayError tests that Replay returns ExitFailure when the record file
+// can not be read.
+func TestReplayError(t *testing.T) {
+	main := build(list{"test", "cmd"})
+
+	status := 0
+	capture(t, func() {
+		status = Replay(main, "does-not-exist.json")
+	})
+	if status != ExitFailure {
+		t.Errorf("got exit status %d, want %d", status, ExitFailure)
+	}
+}
+
+// restoreArgs returns a function that, when called, will restore os.Args.
+func restoreArgs() func() {
+	args := os.Args
+
+	return func() {
+		os.Args = args
+	}
+}
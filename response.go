@@ -0,0 +1,142 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode"
+)
+
+// expandResponseFiles returns argv with each argument starting with '@'
+// replaced by the arguments read from the named response file.
+//
+// The arguments in a response file are separated by white space, including
+// newlines.  Single and double quotes can be used to include white space in
+// an argument, and a backslash escapes the next character, except inside
+// single quotes.  Response files can refer to other response files.  The
+// arguments after the "--" terminator, found in argv or in a response file, are
+// not expanded.  A "--" that is the value of a flag defined by a command of
+// the main command tree is not a terminator.
+func expandResponseFiles(main *Command, argv []string) ([]string, error) {
+	args, _, err := expand(argv, nil, main.lookupTreeFlag)
+
+	return args, err
+}
+
+// expand implements expandResponseFiles, using stack to detect cycles and
+// lookup to find the flags.  It reports whether the "--" terminator was found.
+func expand(argv []string, stack []string,
+	lookup func(string) *flag.Flag) ([]string, bool, error) {
+	var args []string
+	value := false // arg is the value of the previous flag
+	for i, arg := range argv {
+		if arg == "--" && !value {
+			return append(args, argv[i:]...), true, nil
+		}
+		if !strings.HasPrefix(arg, "@") {
+			args = append(args, arg)
+			value = !value && flagValue(arg, lookup)
+
+			continue
+		}
+
+		path := arg[1:]
+		for _, p := range stack {
+			if p == path {
+				return nil, false, fmt.Errorf("response file %s: cycle "+
+					"detected", path)
+			}
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, false, fmt.Errorf("response file: %v", err)
+		}
+		list, err := splitArgs(string(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("response file %s: %v", path, err)
+		}
+		list, done, err := expand(list, append(stack, path), lookup)
+		if err != nil {
+			return nil, false, err
+		}
+		args = append(args, list...)
+		if done {
+			return append(args, argv[i+1:]...), true, nil
+		}
+		value = len(list) > 0 && flagValue(list[len(list)-1], lookup)
+	}
+
+	return args, false, nil
+}
+
+// lookupTreeFlag returns the named flag defined by c or by one of its sub
+// commands, or nil if the flag is not defined.
+func (c *Command) lookupTreeFlag(name string) *flag.Flag {
+	var found *flag.Flag
+	c.Walk(func(c *Command) error {
+		if found = c.lookupFlag(name); found != nil {
+			return errFound
+		}
+
+		return nil
+	})
+
+	return found
+}
+
+// errFound is used by lookupTreeFlag to stop walking the command tree.
+var errFound = errors.New("flag found")
+
+// splitArgs splits s into arguments, as documented in expandResponseFiles.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	var quote rune  // current quote character, if any
+	inArg := false  // an argument is being parsed
+	escape := false // the previous character was a backslash
+	for _, r := range s {
+		switch {
+		case escape:
+			arg.WriteRune(r)
+			escape = false
+		case r == '\\' && quote != '\'':
+			escape = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	switch {
+	case escape:
+		return nil, errors.New("unterminated escape sequence")
+	case quote != 0:
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestSplitArgs tests the splitArgs function.
+func TestSplitArgs(t *testing.T) {
+	var tests = []struct {
+		s    string
+		want list
+		err  bool // expected error
+	}{
+		{"", nil, false},
+		{"a b\tc\nd", list{"a", "b", "c", "d"}, false},
+		{"  a  \n\n b  ", list{"a", "b"}, false},
+		{`"a b" 'c d'`, list{"a b", "c d"}, false},
+		{`a"b c"d`, list{"ab cd"}, false},
+		{`"" ''`, list{"", ""}, false},
+		{`a\ b \"c\"`, list{"a b", `"c"`}, false},
+		{`'a\b' "a\"b"`, list{`a\b`, `a"b`}, false},
+		{`"a`, nil, true},
+		{`a\`, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.s), func(t *testing.T) {
+			got, err := splitArgs(test.s)
+			if test.err {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestParseResponseFiles tests the Parse function, when the main command has
+// the ExpandResponseFiles field set to true.
+func TestParseResponseFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "response")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"args":   "cmd -flag\n'a b'\n",
+		"nested": "cmd @" + filepath.Join(dir, "more"),
+		"more":   "-flag c",
+		"cycle":  "@" + filepath.Join(dir, "cycle"),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		argv list
+		args list // expected arguments
+		err  bool // expected error
+	}{
+		{list{"@args"}, list{"a b"}, false},
+		{list{"@args", "d"}, list{"a b", "d"}, false},
+		{list{"@nested"}, list{"c"}, false},
+		{list{"@cycle"}, nil, true},
+		{list{"@missing"}, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := build(list{"test", "cmd"})
+			main.ExpandResponseFiles = true
+			flag := main.Commands[0].Flag.Bool("flag", false, "flag")
+
+			argv := make(list, len(test.argv))
+			for i, arg := range test.argv {
+				argv[i] = arg
+				if arg[0] == '@' {
+					argv[i] = "@" + filepath.Join(dir, arg[1:])
+				}
+			}
+			cmd, err := Parse(main, argv)
+			if test.err {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !*flag {
+				t.Errorf("flag not set")
+			}
+			if args := cmd.Flag.Args(); !reflect.DeepEqual(args, test.args) {
+				t.Errorf("got arguments %q, want %q", args, test.args)
+			}
+		})
+	}
+}
+
+// TestExpandResponseFilesTerminator tests that the arguments after the "--"
+// terminator are not expanded, unless "--" is a flag value.
+func TestExpandResponseFilesTerminator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "response")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"term":  "a -- @b",
+		"value": "b",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		argv list
+		want list // expected arguments
+	}{
+		{list{"a", "--", "@missing", "--"}, list{"a", "--", "@missing", "--"}},
+		{list{"@term", "@missing"}, list{"a", "--", "@b", "@missing"}},
+		{list{"-sep", "--", "@value"}, list{"-sep", "--", "b"}},
+		{list{"-sep=x", "--", "@missing"}, list{"-sep=x", "--", "@missing"}},
+		{list{"-v", "--", "@missing"}, list{"-v", "--", "@missing"}},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(join(test.argv)), func(t *testing.T) {
+			main := build(list{"test", "cmd"})
+			main.Commands[0].Flag.String("sep", "", "separator")
+			main.Flag.Bool("v", false, "verbose")
+
+			argv := make(list, len(test.argv))
+			for i, arg := range test.argv {
+				argv[i] = arg
+				if arg[0] == '@' && arg != "@missing" {
+					argv[i] = "@" + filepath.Join(dir, arg[1:])
+				}
+			}
+			args, err := expandResponseFiles(main, argv)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(args, test.want) {
+				t.Errorf("got arguments %q, want %q", args, test.want)
+			}
+		})
+	}
+}
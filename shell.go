@@ -0,0 +1,70 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ErrUnknownShell is the error returned by DetectShell when the current shell
+// can not be detected.
+var ErrUnknownShell = errors.New("unknown shell")
+
+// DetectShell returns the name of the current shell, one of bash, zsh, fish or
+// powershell.  The shell is detected using the name of the parent process,
+// where available, and then the SHELL environment variable.
+func DetectShell() (string, error) {
+	return detectShell(parentName(), os.Getenv("SHELL"))
+}
+
+// detectShell implements DetectShell, with parent being the name of the
+// parent process and shell the value of the SHELL environment variable.
+func detectShell(parent, shell string) (string, error) {
+	if name := shellName(parent); name != "" {
+		return name, nil
+	}
+	if name := shellName(shell); name != "" {
+		return name, nil
+	}
+	if shell != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnknownShell, shell)
+	}
+
+	return "", ErrUnknownShell
+}
+
+// shellName returns the name of the shell executable at path, or an empty
+// string if it is not a supported shell.
+func shellName(path string) string {
+	// Handle both slash and backslash separated paths on all systems.
+	name := path[strings.LastIndexAny(path, `/\`)+1:]
+	name = strings.TrimPrefix(name, "-") // login shell
+	name = strings.TrimSuffix(strings.ToLower(name), ".exe")
+	switch name {
+	case "bash", "zsh", "fish":
+		return name
+	case "pwsh", "powershell":
+		return "powershell"
+	}
+
+	return ""
+}
+
+// parentName returns the name of the parent process, or an empty string if
+// it is not available.  Currently this is only supported on systems with a
+// Linux compatible /proc file system.
+func parentName() string {
+	path := fmt.Sprintf("/proc/%d/comm", os.Getppid())
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestDetectShell tests the shell detection used by DetectShell.
+func TestDetectShell(t *testing.T) {
+	var tests = []struct {
+		parent string
+		shell  string
+		want   string // expected shell, or empty on error
+	}{
+		{"bash", "/bin/zsh", "bash"},
+		{"-zsh", "", "zsh"},
+		{"go", "/usr/bin/fish", "fish"},
+		{"", "/bin/bash", "bash"},
+		{"pwsh", "", "powershell"},
+		{"", `C:\Program Files\PowerShell\7\pwsh.exe`, "powershell"},
+		{"go", "/bin/tcsh", ""},
+		{"", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.parent+":"+test.shell), func(t *testing.T) {
+			got, err := detectShell(test.parent, test.shell)
+			if test.want == "" {
+				if !errors.Is(err, ErrUnknownShell) {
+					t.Errorf("got error %v, want %v", err, ErrUnknownShell)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"flag"
+	"strings"
+)
+
+// A Spec is a framework agnostic specification of a command, that makes it
+// easier to define commands for other packages, like spf13/cobra.
+type Spec struct {
+	// Use is the one-line usage message, starting with the command name.
+	Use string
+
+	// Short is the short description shown in the 'cmd -help' output.
+	Short string
+
+	// Long is the long message shown in the command default usage output.
+	Long string
+
+	// Run runs the command and returns the exit status.
+	Run func(cmd *Command, args []string) int
+
+	// Flags lists the flags specific to this command.
+	Flags []FlagSpec
+
+	// Commands lists the available sub commands.
+	Commands []Spec
+}
+
+// A FlagSpec is the specification of a flag.
+type FlagSpec struct {
+	// Name is the flag name, without the leading dash.
+	Name string
+
+	// Usage is the flag usage message.
+	Usage string
+
+	// Value is the flag value.  The default value is the value of Value at the
+	// time FromSpec is called.
+	Value flag.Value
+}
+
+// FromSpec returns a new Command as specified by spec.  The command name is
+// the first word of spec.Use, and the remaining words are the UsageLine.
+func FromSpec(spec Spec) *Command {
+	name, usage := spec.Use, ""
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name, usage = name[:i], strings.TrimSpace(name[i:])
+	}
+
+	cmd := &Command{
+		Run:       spec.Run,
+		Name:      name,
+		UsageLine: usage,
+		Short:     spec.Short,
+		Long:      spec.Long,
+	}
+	for _, f := range spec.Flags {
+		cmd.Flag.Var(f.Value, f.Name, f.Usage)
+	}
+	for _, child := range spec.Commands {
+		cmd.Commands = append(cmd.Commands, FromSpec(child))
+	}
+
+	return cmd
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import "testing"
+
+// stringValue is a minimal flag.Value used for testing.
+type stringValue string
+
+func (s *stringValue) Set(v string) error {
+	*s = stringValue(v)
+
+	return nil
+}
+
+func (s *stringValue) String() string {
+	return string(*s)
+}
+
+// TestFromSpec tests the FromSpec function.
+func TestFromSpec(t *testing.T) {
+	value := stringValue("default")
+	spec := Spec{
+		Use:   "test <command> [arguments]",
+		Short: "test command",
+		Commands: []Spec{
+			{
+				Use:   "cmd [-flag value] arg",
+				Short: "sub command",
+				Flags: []FlagSpec{
+					{Name: "flag", Usage: "flag", Value: &value},
+				},
+			},
+		},
+	}
+
+	main := FromSpec(spec)
+	if main.Name != "test" {
+		t.Errorf("got name %q, want %q", main.Name, "test")
+	}
+	if main.UsageLine != "<command> [arguments]" {
+		t.Errorf("got usage line %q, want %q", main.UsageLine,
+			"<command> [arguments]")
+	}
+	if len(main.Commands) != 1 {
+		t.Fatalf("got %d commands, want 1", len(main.Commands))
+	}
+
+	cmd, err := Parse(main, list{"cmd", "-flag", "value", "arg"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if cmd.Name != "cmd" {
+		t.Errorf("got command %q, want %q", cmd.Name, "cmd")
+	}
+	if value != "value" {
+		t.Errorf("got flag %q, want %q", value, "value")
+	}
+	if f := cmd.Flag.Lookup("flag"); f.DefValue != "default" {
+		t.Errorf("got default %q, want %q", f.DefValue, "default")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import "sort"
+
+// Suggestions returns the names of the candidates that are close to name,
+// ordered by distance.  A candidate is close when its Levenshtein distance
+// from name is at most 2, or at most a quarter of the length of name.  Hidden
+// commands are never suggested.
+func Suggestions(name string, candidates []*Command) []string {
+	max := len([]rune(name)) / 4
+	if max < 2 {
+		max = 2
+	}
+
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	var list []suggestion
+	for _, cmd := range candidates {
+		if cmd.Hidden {
+			continue
+		}
+		if d := levenshtein(name, cmd.Name); d <= max {
+			list = append(list, suggestion{cmd.Name, d})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].distance < list[j].distance
+	})
+
+	names := make([]string, 0, len(list))
+	for _, s := range list {
+		names = append(names, s.name)
+	}
+
+	return names
+}
+
+// levenshtein returns the Levenshtein distance between a and b.
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+
+	// Only keep the previous row of the distance matrix.
+	row := make([]int, len(t)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		prev := row[0] // the value of row[j-1] in the previous row
+		row[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			next := min3(row[j]+1, row[j-1]+1, prev+cost)
+			prev = row[j]
+			row[j] = next
+		}
+	}
+
+	return row[len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+
+	return a
+}
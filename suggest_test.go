@@ -0,0 +1,65 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSuggestions tests that Suggestions returns the close candidates,
+// ordered by distance.
+func TestSuggestions(t *testing.T) {
+	var candidates = []*Command{
+		{Name: "install"},
+		{Name: "list"},
+		{Name: "uninstall"},
+		{Name: "configuration"},
+		{Name: "lost", Hidden: true},
+	}
+	var tests = []struct {
+		name string
+		want list
+	}{
+		{"instal", list{"install"}},
+		{"lsit", list{"list"}},
+		{"nistall", list{"install", "uninstall"}},
+		{"configurtaoin", list{"configuration"}},
+		{"build", list{}},
+		{"", list{}},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.name), func(t *testing.T) {
+			got := Suggestions(test.name, candidates)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestLevenshtein tests the Levenshtein distance.
+func TestLevenshtein(t *testing.T) {
+	var tests = []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"ß", "ss", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.a+" "+test.b), func(t *testing.T) {
+			if got := levenshtein(test.a, test.b); got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
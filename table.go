@@ -0,0 +1,92 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// A Table formats rows of text in aligned columns.  The zero value is an
+// empty table ready to use.
+type Table struct {
+	// Header is the optional header row.
+	Header []string
+
+	// MinWidth is the minimal width of a column, excluding the padding.
+	MinWidth int
+
+	// MaxWidth is the maximum width of a line.  When not zero, longer lines
+	// are truncated and terminated with an ellipsis.
+	MaxWidth int
+
+	rows [][]string
+}
+
+// tablePadding is the number of spaces separating the columns of a Table.
+const tablePadding = 2
+
+// AddRow adds a row with the specified columns.  Tabs and newlines in a
+// column are replaced with spaces.
+func (t *Table) AddRow(cols ...string) {
+	t.rows = append(t.rows, cols)
+}
+
+// Render writes the table to w.
+func (t *Table) Render(w io.Writer) error {
+	var b bytes.Buffer
+	minwidth := t.MinWidth + tablePadding // tabwriter includes the padding
+	tw := tabwriter.NewWriter(&b, minwidth, 0, tablePadding, ' ', 0)
+	if t.Header != nil {
+		writeRow(tw, t.Header)
+	}
+	for _, row := range t.rows {
+		writeRow(tw, row)
+	}
+	tw.Flush()
+
+	scanner := bufio.NewScanner(&b)
+	bw := bufio.NewWriter(w)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " ")
+		bw.WriteString(truncate(line, t.MaxWidth))
+		bw.WriteByte('\n')
+	}
+
+	return bw.Flush()
+}
+
+// writeRow writes the cells of row to tw, as tab terminated cells.
+func writeRow(tw io.Writer, row []string) {
+	var b strings.Builder
+	for _, col := range row {
+		b.WriteString(strings.Map(func(r rune) rune {
+			if r == '\t' || r == '\n' || r == '\r' {
+				return ' '
+			}
+
+			return r
+		}, col))
+		b.WriteByte('\t')
+	}
+	b.WriteByte('\n')
+	io.WriteString(tw, b.String())
+}
+
+// truncate truncates s to at most width runes, replacing the last rune with
+// an ellipsis.  A width of 0 means no limit.
+func truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+
+	return string(runes[:width-1]) + "…"
+}
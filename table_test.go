@@ -0,0 +1,78 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestTable tests that Table aligns the columns.
+func TestTable(t *testing.T) {
+	var tests = []struct {
+		name  string
+		table Table
+		want  string
+	}{
+		{
+			"plain",
+			Table{},
+			"a    bb  c\n" +
+				"ddd  e   f\n",
+		},
+		{
+			"header",
+			Table{Header: list{"NAME", "X", "Y"}},
+			"NAME  X   Y\n" +
+				"a     bb  c\n" +
+				"ddd   e   f\n",
+		},
+		{
+			"min width",
+			Table{MinWidth: 4},
+			"a     bb    c\n" +
+				"ddd   e     f\n",
+		},
+		{
+			"max width",
+			Table{Header: list{"NAME", "X", "DESCRIPTION"}, MaxWidth: 12},
+			"NAME  X   D…\n" +
+				"a     bb  c\n" +
+				"ddd   e   f\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.name), func(t *testing.T) {
+			table := test.table
+			table.AddRow("a", "bb", "c")
+			table.AddRow("ddd", "e", "f")
+
+			var b bytes.Buffer
+			if err := table.Render(&b); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := b.String(); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// TestTableSpecialCharacters tests that tabs and newlines in columns do not
+// break the alignment.
+func TestTableSpecialCharacters(t *testing.T) {
+	var table Table
+	table.AddRow("a\tb", "c")
+	table.AddRow("d", "e\nf")
+
+	var b bytes.Buffer
+	table.Render(&b)
+	want := "a b  c\n" +
+		"d    e f\n"
+	if got := b.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
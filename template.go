@@ -0,0 +1,108 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// DefaultUsageTemplate is a text/template producing the same output as the
+// default usage layout.  It can be used as a starting point for a custom
+// template set by SetUsageTemplate.
+const DefaultUsageTemplate = `usage: {{.}} {{.UsageLine}}
+{{flags .}}{{with debugFlags .}}
+debug flags:
+{{.}}{{end}}{{with .Long}}
+{{wrap .}}
+{{end}}{{with .Examples}}
+examples:
+{{range .}}
+{{with .Desc}}	# {{.}}
+{{end}}	{{.Command}}
+{{end}}{{end}}{{range groups .}}
+{{.Heading}}:
+
+{{range .Commands}}	{{pad .Name}} {{description .}}
+{{end}}{{end}}{{with .Topics}}
+additional help topics:
+
+{{range .}}	{{pad .Name}} {{.ShortDescription}}
+{{end}}{{end}}{{with footer .}}
+{{.}}
+{{end}}`
+
+// usageTemplate is the template set by SetUsageTemplate.
+var usageTemplate string
+
+// SetUsageTemplate sets the text/template used by DefaultHelpRenderer, instead
+// of the default usage layout.  An empty text restores the default layout.
+//
+// The template is executed with the *Command as data, and with the following
+// functions:
+//
+//	flags cmd       the visible flags of cmd, like Command.FlagUsages
+//	debugFlags cmd  the hidden flags of cmd, when DebugFlagsEnv is set
+//	wrap text       text wrapped to the width of the usage output
+//	groups cmd      the visible sub commands of cmd, grouped by heading
+//	pad name        name padded to the width of the name column
+//	description cmd the short description of cmd, with its annotations
+//	footer cmd      the usage footer of cmd
+//
+// Each element returned by groups has the Heading and Commands fields.  An
+// error parsing or executing the template is returned by Command.WriteUsage.
+func SetUsageTemplate(text string) {
+	usageTemplate = text
+}
+
+// templateUsage writes the usage of c to w, using the template set by
+// SetUsageTemplate.
+func (c *Command) templateUsage(w io.Writer) error {
+	width := c.nameWidth()
+	columns := outputWidth(w)
+	funcs := template.FuncMap{
+		"flags": func(c *Command) string {
+			var b strings.Builder
+			c.printFlags(&b, false, columns)
+
+			return b.String()
+		},
+		"debugFlags": func(c *Command) string {
+			if !c.showHiddenFlags() {
+				return ""
+			}
+			var b strings.Builder
+			c.printFlags(&b, true, columns)
+
+			return b.String()
+		},
+		"wrap": func(text string) string {
+			return wrap(text, columns)
+		},
+		"groups": func(c *Command) []commandGroup {
+			return commandGroups(visibleCommands(c))
+		},
+		"pad": func(name string) string {
+			return fmt.Sprintf("%-*s", width, name)
+		},
+		"description": (*Command).listDescription,
+		"footer":      (*Command).footer,
+	}
+	tmpl, err := template.New("usage").Funcs(funcs).Parse(usageTemplate)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, c); err != nil {
+		return err
+	}
+	_, err = w.Write(b.Bytes())
+
+	return err
+}
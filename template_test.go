@@ -0,0 +1,90 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestDefaultUsageTemplate tests that DefaultUsageTemplate produces the same
+// output as the default usage layout.
+func TestDefaultUsageTemplate(t *testing.T) {
+	full := completionTree()
+	full.UsageLine = "[flags] command"
+	full.Long = "App manages the remote repositories."
+	full.Footer = "See https://example.com for more information."
+	full.Examples = []Example{
+		{Desc: "list the remotes", Command: "app remote"},
+		{Command: "app -v remote add origin"},
+	}
+	full.Topics = []*HelpTopic{{Name: "config", Short: "configuration"}}
+	full.Commands = append(full.Commands,
+		&Command{Name: "status", Group: "main commands"},
+		&Command{Name: "old", Deprecated: "do not use"})
+
+	var tests = []struct {
+		name  string
+		cmd   *Command
+		debug bool // set DebugFlagsEnv
+	}{
+		{"empty", &Command{Name: "test"}, false},
+		{"full", full, false},
+		{"debug", full, true},
+		{"leaf", completionTree().Commands[0].Commands[0], false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.debug {
+				os.Setenv(DebugFlagsEnv, "1")
+				defer os.Unsetenv(DebugFlagsEnv)
+			}
+			want := render(t, test.cmd)
+
+			defer SetUsageTemplate("")
+			SetUsageTemplate(DefaultUsageTemplate)
+			if got := render(t, test.cmd); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// TestSetUsageTemplate tests that the default usage output is rendered with
+// the template set by SetUsageTemplate.
+func TestSetUsageTemplate(t *testing.T) {
+	defer SetUsageTemplate("")
+	SetUsageTemplate("Usage of {{.}}:{{range groups .}}" +
+		"{{range .Commands}} {{.Name}}{{end}}{{end}}\n")
+
+	main := build(list{"test", "cmd"})
+	want := "Usage of test: cmd\n"
+	if got := render(t, main); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var cmd Command
+	SetUsageTemplate("{{.Invalid")
+	if err := cmd.WriteUsage(ioutil.Discard); err == nil {
+		t.Errorf("expected error for an invalid template")
+	}
+
+	// The usage error is reported by Run.
+	SetUsageTemplate("{{.Bogus}}")
+	status := 0
+	output := capture(t, func() {
+		status = run(main, list{"app", "-h"})
+	})
+	if status != ExitUsageError {
+		t.Errorf("got status %d, want %d", status, ExitUsageError)
+	}
+	want = "app: usage: template: usage:1:2: executing \"usage\" at " +
+		"<.Bogus>: can't evaluate field Bogus in type *cmd.Command\n"
+	if output != want {
+		t.Errorf("got output %q, want %q", output, want)
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// winsize is the terminal window size, as returned by TIOCGWINSZ.
+type winsize struct {
+	row, col       uint16
+	xpixel, ypixel uint16
+}
+
+// fileWidth returns the width of the terminal f is connected to, or 0 if f
+// is not a terminal.
+func fileWidth(f *os.File) int {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(),
+		uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0
+	}
+
+	return int(ws.col)
+}
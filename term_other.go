@@ -0,0 +1,18 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package cmd
+
+import (
+	"os"
+)
+
+// fileWidth returns the width of the terminal f is connected to.  It always
+// returns 0, since the terminal size is not supported on this platform.
+func fileWidth(f *os.File) int {
+	return 0
+}
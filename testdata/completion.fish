@@ -0,0 +1,15 @@
+# fish completion for app
+
+complete -c 'app' -n 'not __fish_seen_subcommand_from remote' -f -a 'remote' -d 'manage remotes'
+complete -c 'app' -n 'not __fish_seen_subcommand_from remote' -o 'config' -d 'the config file [ini]' -r
+complete -c 'app' -n 'not __fish_seen_subcommand_from remote' -o 'v' -d 'verbose output'
+
+complete -c 'app' -n '__fish_seen_subcommand_from remote; and not __fish_seen_subcommand_from add remove' -f -a 'add' -d 'add a remote'
+complete -c 'app' -n '__fish_seen_subcommand_from remote; and not __fish_seen_subcommand_from add remove' -f -a 'remove' -d 'remove a remote'
+complete -c 'app' -n '__fish_seen_subcommand_from remote; and not __fish_seen_subcommand_from add remove' -o 'all' -d 'all remotes'
+complete -c 'app' -n '__fish_seen_subcommand_from remote; and not __fish_seen_subcommand_from add remove' -o 'v' -d 'verbose output'
+
+complete -c 'app' -n '__fish_seen_subcommand_from remote; and __fish_seen_subcommand_from add' -o 'url' -d 'remote url' -r
+complete -c 'app' -n '__fish_seen_subcommand_from remote; and __fish_seen_subcommand_from add' -o 'v' -d 'verbose output'
+
+complete -c 'app' -n '__fish_seen_subcommand_from remote; and __fish_seen_subcommand_from remove' -o 'v' -d 'verbose output'
@@ -0,0 +1,66 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"flag"
+)
+
+// A TreeNode is the serializable structure of a command, as returned by
+// Command.Tree.  It is designed to be encoded as JSON, for use by external
+// tools like documentation generators and shell plugins.
+type TreeNode struct {
+	Name            string      `json:"name"`
+	UsageLine       string      `json:"usage_line,omitempty"`
+	Short           string      `json:"short,omitempty"`
+	Hidden          bool        `json:"hidden,omitempty"`
+	Flags           []TreeFlag  `json:"flags,omitempty"`
+	PersistentFlags []TreeFlag  `json:"persistent_flags,omitempty"`
+	Commands        []*TreeNode `json:"commands,omitempty"`
+}
+
+// A TreeFlag is the serializable structure of a flag, as returned by
+// Command.Tree.
+type TreeFlag struct {
+	Name    string `json:"name"`
+	Default string `json:"default"`
+	Usage   string `json:"usage"`
+	Hidden  bool   `json:"hidden,omitempty"`
+}
+
+// Tree returns the structure of c and of all its sub commands, in declaration
+// order and including the hidden commands and flags.  The flags are sorted by
+// name, and the default value of a flag is the string representation of its
+// flag.Value when it was defined.
+func (c *Command) Tree() *TreeNode {
+	node := &TreeNode{
+		Name:            c.Name,
+		UsageLine:       c.UsageLine,
+		Short:           c.ShortDescription(),
+		Hidden:          c.Hidden,
+		Flags:           c.treeFlags(&c.Flag),
+		PersistentFlags: c.treeFlags(&c.PersistentFlag),
+	}
+	for _, cmd := range c.Commands {
+		node.Commands = append(node.Commands, cmd.Tree())
+	}
+
+	return node
+}
+
+// treeFlags returns the structure of the flags in fs, defined by c.
+func (c *Command) treeFlags(fs *flag.FlagSet) []TreeFlag {
+	var flags []TreeFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, TreeFlag{
+			Name:    f.Name,
+			Default: f.DefValue,
+			Usage:   f.Usage,
+			Hidden:  c.hiddenFlags[f.Name],
+		})
+	})
+
+	return flags
+}
@@ -0,0 +1,34 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestCommandTree tests the JSON encoding of the command tree with a golden
+// file, and that it can be decoded back.
+func TestCommandTree(t *testing.T) {
+	main := completionTree()
+	main.UsageLine = "[flags] command [args]"
+	main.Flag.Int("n", 10, "number of `jobs`")
+
+	tree := main.Tree()
+	data, err := json.MarshalIndent(tree, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "tree.json", append(data, '\n'))
+
+	var got *TreeNode
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, tree) {
+		t.Errorf("got %+v, want %+v", got, tree)
+	}
+}
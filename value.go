@@ -0,0 +1,48 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+)
+
+// regexpValue is a string flag.Value that must match a regular expression.
+type regexpValue struct {
+	p  *string
+	re *regexp.Regexp
+}
+
+// NewRegexpValue returns a flag.Value that stores in p the values matching
+// the regular expression pattern, and rejects the other values.  The current
+// value of p is used as the default value.  The pattern is not implicitly
+// anchored, so it should usually start with ^ and end with $.
+func NewRegexpValue(p *string, pattern string) (flag.Value, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &regexpValue{p: p, re: re}, nil
+}
+
+func (v *regexpValue) Set(s string) error {
+	if !v.re.MatchString(s) {
+		return fmt.Errorf("%q does not match pattern %q", s, v.re)
+	}
+	*v.p = s
+
+	return nil
+}
+
+func (v *regexpValue) String() string {
+	if v.p == nil {
+		// Called by flag.PrintDefaults on the zero value.
+		return ""
+	}
+
+	return *v.p
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"testing"
+)
+
+// TestRegexpValue tests that the value returned by NewRegexpValue only
+// accepts the values matching the pattern.
+func TestRegexpValue(t *testing.T) {
+	const pattern = `^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	var tests = []struct {
+		arg  string
+		want string // expected error
+	}{
+		{"example", ""},
+		{"web-1", ""},
+		{"Example", `"Example" does not match pattern "` + pattern + `"`},
+		{"-web", `"-web" does not match pattern "` + pattern + `"`},
+		{"", `"" does not match pattern "` + pattern + `"`},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.arg), func(t *testing.T) {
+			name := "default"
+			value, err := NewRegexpValue(&name, pattern)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			err = value.Set(test.arg)
+			if test.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if name != test.arg {
+					t.Errorf("got %q, want %q", name, test.arg)
+				}
+
+				return
+			}
+			if err == nil || err.Error() != test.want {
+				t.Errorf("got error %v, want %s", err, test.want)
+			}
+			if name != "default" {
+				t.Errorf("got %q, want %q", name, "default")
+			}
+		})
+	}
+}
+
+// TestRegexpValueFlag tests that an invalid value is reported by Parse, and
+// that the default value is documented.
+func TestRegexpValueFlag(t *testing.T) {
+	main := build(list{"test"})
+	name := "web"
+	value, err := NewRegexpValue(&name, `^[a-z]+$`)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	main.Flag.Var(value, "name", "host `name`")
+
+	want := "usage: test \n  -name name\n    \thost name (default web)\n"
+	if usage := render(t, main); usage != want {
+		t.Errorf("got usage %q, want %q", usage, want)
+	}
+
+	_, err = Parse(main, list{"-name", "WEB"})
+	want = `invalid value "WEB" for flag -name: ` +
+		`"WEB" does not match pattern "^[a-z]+$"`
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+// TestRegexpValueInvalidPattern tests that NewRegexpValue reports an invalid
+// pattern.
+func TestRegexpValueInvalidPattern(t *testing.T) {
+	var name string
+	if _, err := NewRegexpValue(&name, `[a-z`); err == nil {
+		t.Errorf("expected error")
+	}
+}
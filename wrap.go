@@ -0,0 +1,85 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultUsageWidth is the width of the default usage output when the width
+// is not set by SetUsageWidth and the output is not a terminal.
+const defaultUsageWidth = 80
+
+// usageWidth is the width set by SetUsageWidth.
+var usageWidth int
+
+// SetUsageWidth sets the width, in columns, used to wrap the Long text and the
+// flag usage messages in the default usage output.  A width less than or
+// equal to 0 restores the default width, that is the width of the terminal
+// the usage is written to, or 80 columns.
+func SetUsageWidth(width int) {
+	usageWidth = width
+}
+
+// outputWidth returns the width of the default usage output written to w.
+func outputWidth(w io.Writer) int {
+	if usageWidth > 0 {
+		return usageWidth
+	}
+
+	return terminalWidth(w)
+}
+
+// terminalWidth returns the width of the terminal w is connected to, or 80 if
+// w is not a terminal.
+func terminalWidth(w io.Writer) int {
+	if f, ok := w.(*os.File); ok {
+		if width := fileWidth(f); width > 0 {
+			return width
+		}
+	}
+
+	return defaultUsageWidth
+}
+
+// wrap returns s with the lines longer than width wrapped at word boundaries.
+// The short lines, the empty lines and the lines starting with a space or a
+// tab, like indented code, are preserved.  A word longer than width is not
+// split.
+func wrap(s string, width int) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if len(line) <= width || strings.IndexAny(line, " \t") == 0 {
+			continue
+		}
+		lines[i] = wrapLine(line, width)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// wrapLine returns line wrapped at word boundaries, so that each line is at
+// most width bytes long, unless it is a single word.
+func wrapLine(line string, width int) string {
+	var b strings.Builder
+	n := 0 // length of the current line
+	for _, word := range strings.Fields(line) {
+		switch {
+		case n == 0:
+		case n+1+len(word) > width:
+			b.WriteByte('\n')
+			n = 0
+		default:
+			b.WriteByte(' ')
+			n++
+		}
+		b.WriteString(word)
+		n += len(word)
+	}
+
+	return b.String()
+}
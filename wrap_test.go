@@ -0,0 +1,94 @@
+// Copyright 2020 Manlio Perillo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestWrap tests that wrap wraps the long lines at word boundaries,
+// preserving the short lines, the empty lines and the indented lines.
+func TestWrap(t *testing.T) {
+	var tests = []struct {
+		s    string
+		want string
+	}{
+		{"", ""},
+		{"short line", "short line"},
+		{"one two three four", "one two\nthree four"},
+		{"one  two   three", "one two\nthree"},
+		{"a\n\nb c d e f g h", "a\n\nb c d e f\ng h"},
+		{"\tindented code line", "\tindented code line"},
+		{"averyveryverylongword x", "averyveryverylongword\nx"},
+		{"one two\nthree four five", "one two\nthree four\nfive"},
+	}
+
+	for _, test := range tests {
+		t.Run(mkname(test.s), func(t *testing.T) {
+			if got := wrap(test.s, 10); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestUsageWrap tests that the default usage output wraps the Long text and
+// the flag usage messages to the width set by SetUsageWidth.
+func TestUsageWrap(t *testing.T) {
+	defer SetUsageWidth(usageWidth)
+	SetUsageWidth(31)
+
+	cmd := &Command{
+		Name: "test",
+		Long: "Test is a command with a long description.\n\n" +
+			"\ttest -n 10 some arguments",
+	}
+	cmd.Flag.Int("n", 1, "the number of parallel jobs to run")
+
+	want := strings.Join([]string{
+		"usage: test ",
+		"  -n int",
+		"    \tthe number of parallel",
+		"    \tjobs to run (default 1)",
+		"",
+		"Test is a command with a long",
+		"description.",
+		"",
+		"\ttest -n 10 some arguments",
+		"",
+	}, "\n")
+	if got := render(t, cmd); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestTerminalWidth tests that terminalWidth returns the default width when
+// the writer is not a terminal, and that SetUsageWidth overrides it.
+func TestTerminalWidth(t *testing.T) {
+	var buf bytes.Buffer
+	if got := terminalWidth(&buf); got != defaultUsageWidth {
+		t.Errorf("got %d, want %d", got, defaultUsageWidth)
+	}
+
+	file, err := ioutil.TempFile("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if got := terminalWidth(file); got != defaultUsageWidth {
+		t.Errorf("got %d, want %d", got, defaultUsageWidth)
+	}
+
+	defer SetUsageWidth(usageWidth)
+	SetUsageWidth(100)
+	if got := outputWidth(&buf); got != 100 {
+		t.Errorf("got %d, want %d", got, 100)
+	}
+}